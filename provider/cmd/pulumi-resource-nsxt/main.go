@@ -19,8 +19,6 @@ package main
 import (
 	_ "embed"
 
-	"github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfbridge"
-	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/version"
 	nsxt "github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider"
 )
 
@@ -28,6 +26,6 @@ import (
 var pulumiSchema []byte
 
 func main() {
-	tfbridge.Main("nsxt", version.Version, nsxt.Provider(), pulumiSchema)
+	nsxt.Main(pulumiSchema)
 	
 }
@@ -0,0 +1,158 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+// commandTimeout bounds the time passwordCommand may take.
+const commandTimeout = 30 * time.Second
+
+// credentialSource lists the ways to give one credential of the upstream provider: inline, in a
+// file or printed by a command. Each one is a Pulumi configuration key and its environment
+// variable.
+type credentialSource struct {
+	setting                    string // upstream setting receiving the credential
+	inline, file, command      string
+	inlineEnv, fileEnv, cmdEnv string
+}
+
+var credentialSources = []credentialSource{
+	{
+		setting: "password",
+		inline:  "password", inlineEnv: "NSXT_PASSWORD",
+		file: "passwordFile", fileEnv: "NSXT_PASSWORD_FILE",
+		command: "passwordCommand", cmdEnv: "NSXT_PASSWORD_COMMAND",
+	},
+	{
+		setting: "vmc_token",
+		inline:  "vmcToken", inlineEnv: "NSXT_VMC_TOKEN",
+		file: "vmcTokenFile", fileEnv: "NSXT_VMC_TOKEN_FILE",
+	},
+}
+
+// resolveCredentials reads the credentials given by file or command, keyed by the upstream
+// setting they are for.
+func resolveCredentials(ctx context.Context, vars resource.PropertyMap) (map[string]string, error) {
+	credentials := map[string]string{}
+	for _, src := range credentialSources {
+		file := stringValue(vars, src.file, src.fileEnv)
+		var command string
+		if src.command != "" {
+			command = stringValue(vars, src.command, src.cmdEnv)
+		}
+
+		var given []string
+		if stringValue(vars, src.inline, src.inlineEnv) != "" {
+			given = append(given, src.inline)
+		}
+		if file != "" {
+			given = append(given, src.file)
+		}
+		if command != "" {
+			given = append(given, src.command)
+		}
+		if len(given) > 1 {
+			return nil, fmt.Errorf("%s are mutually exclusive: set only one of them, in the "+
+				"configuration or as NSXT_* environment variable", strings.Join(given, " and "))
+		}
+
+		switch {
+		case file != "":
+			secret, err := readSecretFile(src.file, file)
+			if err != nil {
+				return nil, err
+			}
+			credentials[src.setting] = secret
+		case command != "":
+			secret, err := runSecretCommand(ctx, src.command, command)
+			if err != nil {
+				return nil, err
+			}
+			credentials[src.setting] = secret
+		}
+	}
+	return credentials, nil
+}
+
+func readSecretFile(key, path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+	secret := strings.TrimRight(string(content), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("%s: %s is empty", key, path)
+	}
+	return secret, nil
+}
+
+// runSecretCommand runs command with the shell of the platform and returns what it printed on
+// stdout, without the trailing newline. Errors hold what it printed on stderr, but neither the
+// command nor its stdout.
+func runSecretCommand(ctx context.Context, key, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	shell, flag := "/bin/sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd := exec.CommandContext(ctx, shell, flag, command)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s failed: %w: %s", key, err, msg)
+		}
+		return "", fmt.Errorf("%s failed: %w", key, err)
+	}
+	secret := strings.TrimRight(string(out), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("%s printed nothing on stdout", key)
+	}
+	return secret, nil
+}
+
+// stringValue returns the configuration value of key, or of the first environment variable of
+// envs set when key isn't configured. Unknown values are empty.
+func stringValue(vars resource.PropertyMap, key string, envs ...string) string {
+	if v, ok := vars[resource.PropertyKey(key)]; ok {
+		if v.IsSecret() {
+			v = v.SecretValue().Element
+		}
+		if v.IsString() {
+			return v.StringValue()
+		}
+		return ""
+	}
+	for _, env := range envs {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+func TestResolveCredentials(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are written for /bin/sh")
+	}
+	file := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(file, []byte("from-file\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		secrets map[string]string
+		env     map[string]string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "none",
+			want: map[string]string{},
+		},
+		{
+			name:   "inline is left to the upstream provider",
+			config: map[string]interface{}{"password": "inline"},
+			want:   map[string]string{},
+		},
+		{
+			name:   "file without its trailing newline",
+			config: map[string]interface{}{"passwordFile": file},
+			want:   map[string]string{"password": "from-file"},
+		},
+		{
+			name:   "command output without its trailing newline",
+			config: map[string]interface{}{"passwordCommand": `printf ' from command \n\n'`},
+			want:   map[string]string{"password": " from command "},
+		},
+		{
+			name:    "secret configuration",
+			secrets: map[string]string{"passwordCommand": "echo secret"},
+			want:    map[string]string{"password": "secret"},
+		},
+		{
+			name: "environment",
+			env:  map[string]string{"NSXT_PASSWORD_FILE": file, "NSXT_VMC_TOKEN_FILE": file},
+			want: map[string]string{"password": "from-file", "vmc_token": "from-file"},
+		},
+		{
+			name:   "configuration wins over environment",
+			config: map[string]interface{}{"passwordFile": file},
+			env:    map[string]string{"NSXT_PASSWORD_FILE": empty},
+			want:   map[string]string{"password": "from-file"},
+		},
+		{
+			name:    "inline and file",
+			config:  map[string]interface{}{"password": "inline", "passwordFile": file},
+			wantErr: "password and passwordFile are mutually exclusive",
+		},
+		{
+			name:    "file in the configuration and command in the environment",
+			config:  map[string]interface{}{"passwordFile": file},
+			env:     map[string]string{"NSXT_PASSWORD_COMMAND": "echo secret"},
+			wantErr: "passwordFile and passwordCommand are mutually exclusive",
+		},
+		{
+			name:    "missing file",
+			config:  map[string]interface{}{"vmcTokenFile": filepath.Join(t.TempDir(), "missing")},
+			wantErr: "vmcTokenFile: open",
+		},
+		{
+			name:    "empty file",
+			config:  map[string]interface{}{"passwordFile": empty},
+			wantErr: "passwordFile: " + empty + " is empty",
+		},
+		{
+			name:    "failing command",
+			config:  map[string]interface{}{"passwordCommand": "echo denied >&2; exit 3"},
+			wantErr: "passwordCommand failed: exit status 3: denied",
+		},
+		{
+			name:    "command printing nothing",
+			config:  map[string]interface{}{"passwordCommand": "echo"},
+			wantErr: "passwordCommand printed nothing on stdout",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, src := range credentialSources {
+				for _, env := range []string{src.inlineEnv, src.fileEnv, src.cmdEnv} {
+					if env != "" {
+						t.Setenv(env, tt.env[env])
+					}
+				}
+			}
+			vars := resource.NewPropertyMapFromMap(tt.config)
+			for k, v := range tt.secrets {
+				vars[resource.PropertyKey(k)] = resource.MakeSecret(resource.NewStringProperty(v))
+			}
+			got, err := resolveCredentials(context.Background(), vars)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveCredentials() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("resolveCredentials() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("resolveCredentials()[%s] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+// The errors end up in the engine output: they name the settings, never the command, which may
+// hold a secret, nor what it printed on stdout.
+func TestResolveCredentialsKeepsSecretsOutOfErrors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are written for /bin/sh")
+	}
+	const secret = "hunter2"
+	for _, command := range []string{
+		"echo " + secret + "; exit 1",
+		"true " + secret,
+	} {
+		vars := resource.PropertyMap{"passwordCommand": resource.MakeSecret(resource.NewStringProperty(command))}
+		_, err := resolveCredentials(context.Background(), vars)
+		if err == nil || strings.Contains(err.Error(), secret) {
+			t.Errorf("resolveCredentials() with %q error = %v, want an error without the secret", command, err)
+		}
+	}
+}
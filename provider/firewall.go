@@ -0,0 +1,290 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxt
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfbridge"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/dfw"
+)
+
+// ruleExtractors read the rules of the firewall resources, in evaluation order, and the scopes
+// the rules apply to by default, from their inputs.
+var ruleExtractors = map[string]func(config resource.PropertyMap) ([]dfw.Rule, []string){
+	"nsxt_policy_security_policy":         policyRules,
+	"nsxt_policy_gateway_policy":          policyRules,
+	"nsxt_policy_sharded_security_policy": policyRules,
+	"nsxt_firewall_section":               sectionRules,
+}
+
+// ruleListResources are the resources whose rules are diffed rule by rule rather than by index.
+var ruleListResources = []string{
+	"nsxt_policy_security_policy",
+	"nsxt_policy_gateway_policy",
+	"nsxt_policy_predefined_security_policy",
+	"nsxt_policy_predefined_gateway_policy",
+	"nsxt_policy_sharded_security_policy",
+}
+
+// dfwResources are the resources changing the distributed firewall, saved to a draft before
+// they first do with dfwSnapshot.
+var dfwResources = []string{
+	"nsxt_policy_security_policy",
+	"nsxt_policy_predefined_security_policy",
+	"nsxt_policy_sharded_security_policy",
+	"nsxt_policy_security_policy_rule",
+}
+
+// dfwTypes returns the Pulumi types of the dfwResources.
+func dfwTypes(prov tfbridge.ProviderInfo) []string {
+	return resourceTypes(prov, dfwResources)
+}
+
+// ruleListTypes returns the Pulumi types of the ruleListResources.
+func ruleListTypes(prov tfbridge.ProviderInfo) []string {
+	return resourceTypes(prov, ruleListResources)
+}
+
+// resourceTypes returns the Pulumi types of the resources with the given Terraform names.
+func resourceTypes(prov tfbridge.ProviderInfo, names []string) []string {
+	var types []string
+	for _, name := range names {
+		if info, ok := prov.Resources[name]; ok {
+			types = append(types, string(info.Tok))
+		}
+	}
+	return types
+}
+
+// analyzeRules makes the firewall resources warn, from preview on, about their shadowed, redundant
+// and any-any-allow rules. The analysis only needs the inputs of the resource.
+func analyzeRules(prov *tfbridge.ProviderInfo) {
+	for name, extract := range ruleExtractors {
+		info, ok := prov.Resources[name]
+		if !ok {
+			continue
+		}
+		check, extract := info.PreCheckCallback, extract
+		info.PreCheckCallback = func(ctx context.Context, config, meta resource.PropertyMap) (resource.PropertyMap, error) {
+			if check != nil {
+				var err error
+				if config, err = check(ctx, config, meta); err != nil {
+					return nil, err
+				}
+			}
+			rules, scopes := extract(config)
+			for _, finding := range dfw.Analyze(rules, scopes) {
+				tfbridge.GetLogger(ctx).Warn(finding.String())
+			}
+			return config, nil
+		}
+	}
+}
+
+// policyRules reads the rules of a security or gateway policy. Rules are evaluated by sequence
+// number, then in the order they are given, up to the first one with unknown values: it could
+// match any flow, and be anywhere when its sequence number is unknown.
+func policyRules(config resource.PropertyMap) ([]dfw.Rule, []string) {
+	scopes, ok := stringsProperty(config["scopes"])
+	if !ok {
+		return nil, nil
+	}
+	type numbered struct {
+		rule     dfw.Rule
+		sequence float64
+		known    bool
+	}
+	var rules []numbered
+	for i, p := range objectsProperty(config["rules"]) {
+		var r dfw.Rule
+		known := p != nil
+		for _, field := range []struct {
+			key    resource.PropertyKey
+			target *[]string
+		}{
+			{"sourceGroups", &r.SourceGroups},
+			{"destinationGroups", &r.DestinationGroups},
+			{"services", &r.Services},
+			{"scopes", &r.Scopes},
+			{"profiles", &r.Profiles},
+		} {
+			values, ok := stringsProperty(p[field.key])
+			*field.target, known = values, known && ok
+		}
+		r.Name = ruleName(p, i)
+		r.Action, known = stringProperty(p["action"], "ALLOW", known)
+		r.Direction, known = stringProperty(p["direction"], "IN_OUT", known)
+		r.IPVersion, known = stringProperty(p["ipVersion"], "IPV4_IPV6", known)
+		r.SourcesExcluded, known = boolProperty(p["sourcesExcluded"], known)
+		r.DestinationsExcluded, known = boolProperty(p["destinationsExcluded"], known)
+		r.Disabled, known = boolProperty(p["disabled"], known)
+		var sequence float64
+		switch v := unwrap(p["sequenceNumber"]); {
+		case v.ContainsUnknowns():
+			known, sequence = false, math.Inf(-1)
+		case v.IsNumber():
+			sequence = v.NumberValue()
+		}
+		rules = append(rules, numbered{r, sequence, known})
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].sequence < rules[j].sequence })
+
+	ordered := make([]dfw.Rule, 0, len(rules))
+	for _, r := range rules {
+		if !r.known {
+			break
+		}
+		ordered = append(ordered, r.rule)
+	}
+	return ordered, scopes
+}
+
+// sectionRules reads the rules of a legacy firewall section, evaluated in order up to the first
+// one with unknown values. Their sources, destinations, services and applied-tos are references
+// to objects, compared as such.
+func sectionRules(config resource.PropertyMap) ([]dfw.Rule, []string) {
+	scopes, ok := targetsProperty(config["appliedTos"])
+	if !ok {
+		return nil, nil
+	}
+	var rules []dfw.Rule
+	for i, p := range objectsProperty(config["rules"]) {
+		var r dfw.Rule
+		known := p != nil
+		for _, field := range []struct {
+			key    resource.PropertyKey
+			target *[]string
+		}{
+			{"sources", &r.SourceGroups},
+			{"destinations", &r.DestinationGroups},
+			{"services", &r.Services},
+			{"appliedTos", &r.Scopes},
+		} {
+			values, ok := targetsProperty(p[field.key])
+			*field.target, known = values, known && ok
+		}
+		r.Name = ruleName(p, i)
+		r.Action, known = stringProperty(p["action"], "", known)
+		r.Direction, known = stringProperty(p["direction"], "IN_OUT", known)
+		r.IPVersion, known = stringProperty(p["ipProtocol"], "IPV4_IPV6", known)
+		r.SourcesExcluded, known = boolProperty(p["sourcesExcluded"], known)
+		r.DestinationsExcluded, known = boolProperty(p["destinationsExcluded"], known)
+		r.Disabled, known = boolProperty(p["disabled"], known)
+		if !known {
+			break
+		}
+		rules = append(rules, r)
+	}
+	return rules, scopes
+}
+
+// ruleName names the i-th rule after its display name, else its position.
+func ruleName(p resource.PropertyMap, i int) string {
+	if v := unwrap(p["displayName"]); v.IsString() && v.StringValue() != "" {
+		return `"` + v.StringValue() + `"`
+	}
+	return "#" + strconv.Itoa(i+1)
+}
+
+// unwrap returns the value of a secret, or v.
+func unwrap(v resource.PropertyValue) resource.PropertyValue {
+	for v.IsSecret() {
+		v = v.SecretValue().Element
+	}
+	return v
+}
+
+// objectsProperty reads a list of objects, nil for the ones that aren't known yet.
+func objectsProperty(v resource.PropertyValue) []resource.PropertyMap {
+	v = unwrap(v)
+	if !v.IsArray() {
+		return nil
+	}
+	var objects []resource.PropertyMap
+	for _, item := range v.ArrayValue() {
+		switch item = unwrap(item); {
+		case item.IsObject():
+			objects = append(objects, item.ObjectValue())
+		case item.ContainsUnknowns():
+			objects = append(objects, nil)
+		}
+	}
+	return objects
+}
+
+// stringsProperty reads a list of strings. ok is false when it isn't known yet.
+func stringsProperty(v resource.PropertyValue) (values []string, ok bool) {
+	v = unwrap(v)
+	if v.ContainsUnknowns() {
+		return nil, false
+	}
+	if !v.IsArray() {
+		return nil, true
+	}
+	for _, item := range v.ArrayValue() {
+		if item = unwrap(item); item.IsString() {
+			values = append(values, item.StringValue())
+		}
+	}
+	return values, true
+}
+
+// targetsProperty reads a list of references to objects (targetType, targetId) as type:id.
+func targetsProperty(v resource.PropertyValue) (values []string, ok bool) {
+	v = unwrap(v)
+	if v.ContainsUnknowns() {
+		return nil, false
+	}
+	for _, target := range objectsProperty(v) {
+		kind, id := unwrap(target["targetType"]), unwrap(target["targetId"])
+		if !id.IsString() {
+			continue
+		}
+		value := id.StringValue()
+		if kind.IsString() {
+			value = kind.StringValue() + ":" + value
+		}
+		values = append(values, value)
+	}
+	return values, true
+}
+
+// stringProperty reads a string, or its default when not set. known turns false when the value
+// isn't known yet.
+func stringProperty(v resource.PropertyValue, def string, known bool) (string, bool) {
+	v = unwrap(v)
+	switch {
+	case v.ContainsUnknowns():
+		return "", false
+	case v.IsString() && v.StringValue() != "":
+		return v.StringValue(), known
+	}
+	return def, known
+}
+
+// boolProperty reads a bool, false when not set. known turns false when the value isn't known yet.
+func boolProperty(v resource.PropertyValue, known bool) (bool, bool) {
+	v = unwrap(v)
+	if v.ContainsUnknowns() {
+		return false, false
+	}
+	return v.IsBool() && v.BoolValue(), known
+}
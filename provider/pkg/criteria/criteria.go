@@ -0,0 +1,321 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package criteria compiles membership expressions into the criteria of Policy groups.
+//
+// An expression combines conditions with && and ||, which binds less tightly, and parentheses:
+//
+//	vm.tag[app] == "web" && segment.tag[env] == "prod" || ip in [10.0.0.0/24, 10.1.0.1-10.1.0.9]
+//
+// Conditions select vm, segment, segmentport or ipset members by tag (tag[scope] or tag for any
+// scope) and VMs also by name, os_name or computer_name, with ==, !=, contains, startswith or
+// endswith. ip, mac and path in select static members by IP address, MAC address or Policy path.
+//
+// NSX evaluates the criteria of a group as ANDed criteria separated by ORs, so the expression is
+// expanded into that form. Conditions on one member type ANDed together share a criterion, and
+// static members are merged into one criterion per kind, which NSX only allows to be ORed with
+// the others.
+package criteria
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Limits of NSX on the criteria of a group.
+const (
+	MaxCriteria   = 5
+	MaxConditions = 15
+)
+
+// maxTerms bounds the expansion of an expression, whose size can grow exponentially.
+const maxTerms = 64
+
+// Condition is a condition of a criterion, as the condition blocks of PolicyGroup.
+type Condition struct {
+	MemberType string
+	Key        string
+	Operator   string
+	Value      string
+}
+
+// Criterion is a criteria block of PolicyGroup. It holds either conditions, ANDed, or one kind
+// of static members.
+type Criterion struct {
+	Conditions   []Condition
+	IPAddresses  []string
+	MACAddresses []string
+	MemberPaths  []string
+}
+
+// Criteria are the criteria of a group, with the conjunctions (AND or OR) between them.
+type Criteria struct {
+	Criteria     []Criterion
+	Conjunctions []string
+}
+
+// memberTypes are the member types of conditions, by name in expressions.
+var memberTypes = map[string]string{
+	"vm":          "VirtualMachine",
+	"segment":     "Segment",
+	"segmentport": "SegmentPort",
+	"ipset":       "IPSet",
+}
+
+// conditionKeys are the keys of conditions, by name in expressions.
+var conditionKeys = map[string]string{
+	"tag":           "Tag",
+	"name":          "Name",
+	"os_name":       "OSName",
+	"computer_name": "ComputerName",
+}
+
+// exclusiveMemberTypes are the member types whose conditions NSX doesn't allow to be ANDed with
+// conditions on other member types.
+var exclusiveMemberTypes = map[string]bool{
+	"IPSet": true,
+}
+
+// allowedAttributes are the attributes NSX can select each member type by.
+var allowedAttributes = map[string][]string{
+	"VirtualMachine": {"tag", "name", "os_name", "computer_name"},
+	"Segment":        {"tag"},
+	"SegmentPort":    {"tag"},
+	"IPSet":          {"tag"},
+}
+
+// wordOperators are the operators spelled as words.
+var wordOperators = map[string]string{
+	"contains":   "CONTAINS",
+	"startswith": "STARTSWITH",
+	"endswith":   "ENDSWITH",
+}
+
+// Compile compiles an expression into criteria, or returns an *Error locating the problem.
+func Compile(expression string) (*Criteria, error) {
+	tokens, err := lex(expression)
+	if err != nil {
+		return nil, err
+	}
+	if tokens[0].kind == tokenEOF {
+		return nil, tokens[0].pos.errorf("empty expression")
+	}
+	p := &parser{tokens: tokens}
+	tree, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, t.pos.errorf("expected && or ||, found %s", t)
+	}
+
+	terms, err := expand(tree)
+	if err != nil {
+		return nil, err
+	}
+	return build(terms)
+}
+
+// expand expands a tree into ORed terms of ANDed atoms.
+func expand(n node) ([][]*atom, error) {
+	switch n := n.(type) {
+	case *atom:
+		return [][]*atom{{n}}, nil
+	case or:
+		var terms [][]*atom
+		for _, child := range n {
+			t, err := expand(child)
+			if err != nil {
+				return nil, err
+			}
+			terms = append(terms, t...)
+		}
+		return terms, checkExpansion(terms)
+	case and:
+		terms := [][]*atom{{}}
+		for _, child := range n {
+			t, err := expand(child)
+			if err != nil {
+				return nil, err
+			}
+			var product [][]*atom
+			for _, left := range terms {
+				for _, right := range t {
+					term := append(append([]*atom{}, left...), right...)
+					product = append(product, term)
+				}
+			}
+			terms = product
+			if err := checkExpansion(terms); err != nil {
+				return nil, err
+			}
+		}
+		return terms, nil
+	}
+	return nil, fmt.Errorf("unexpected node %T", n)
+}
+
+func checkExpansion(terms [][]*atom) error {
+	if len(terms) > maxTerms {
+		return terms[0][0].pos.errorf("the expression expands into more than %d alternatives, "+
+			"a group has at most %d criteria", maxTerms, MaxCriteria)
+	}
+	return nil
+}
+
+// build turns expanded terms into criteria.
+func build(terms [][]*atom) (*Criteria, error) {
+	result := &Criteria{}
+	// Static members of each kind are merged into one criterion, at the place of the first term
+	// that has some.
+	static := map[atomKind]int{}
+	add := func(c Criterion, conjunction string) {
+		if len(result.Criteria) > 0 {
+			result.Conjunctions = append(result.Conjunctions, conjunction)
+		}
+		result.Criteria = append(result.Criteria, c)
+	}
+
+	for _, term := range terms {
+		if term[0].kind != atomCondition {
+			if len(term) > 1 {
+				other := term[1]
+				return nil, other.pos.errorf("%s selects static members, which NSX only allows to be "+
+					"combined with other criteria by ||", describe(term[0]))
+			}
+			a := term[0]
+			if i, ok := static[a.kind]; ok {
+				result.Criteria[i] = appendValues(result.Criteria[i], a)
+				continue
+			}
+			static[a.kind] = len(result.Criteria)
+			add(appendValues(Criterion{}, a), "OR")
+			continue
+		}
+
+		// Conditions are grouped by member type, in their order of appearance.
+		var order []string
+		byType := map[string]*Criterion{}
+		for _, a := range term {
+			if a.kind != atomCondition {
+				return nil, a.pos.errorf("%s selects static members, which NSX only allows to be "+
+					"combined with other criteria by ||", describe(a))
+			}
+			c, ok := byType[a.condition.MemberType]
+			if !ok && len(order) > 0 && (exclusiveMemberTypes[a.condition.MemberType] ||
+				exclusiveMemberTypes[order[0]]) {
+				return nil, a.pos.errorf("NSX doesn't allow conditions on %s and %s members to be combined "+
+					"by &&, only by ||", order[0], a.condition.MemberType)
+			}
+			if !ok {
+				c = &Criterion{}
+				byType[a.condition.MemberType] = c
+				order = append(order, a.condition.MemberType)
+			}
+			if !slices.Contains(c.Conditions, a.condition) {
+				c.Conditions = append(c.Conditions, a.condition)
+			}
+			if len(c.Conditions) > MaxConditions {
+				return nil, a.pos.errorf("a criterion has at most %d conditions, this one has more on %s members",
+					MaxConditions, a.condition.MemberType)
+			}
+		}
+		for i, memberType := range order {
+			conjunction := "OR"
+			if i > 0 {
+				conjunction = "AND"
+			}
+			add(*byType[memberType], conjunction)
+		}
+	}
+
+	if len(result.Criteria) > MaxCriteria {
+		return nil, terms[0][0].pos.errorf("the expression needs %d criteria, a group has at most %d",
+			len(result.Criteria), MaxCriteria)
+	}
+	return result, nil
+}
+
+func appendValues(c Criterion, a *atom) Criterion {
+	switch a.kind {
+	case atomIP:
+		c.IPAddresses = appendUnique(c.IPAddresses, a.values)
+	case atomMAC:
+		c.MACAddresses = appendUnique(c.MACAddresses, a.values)
+	case atomPath:
+		c.MemberPaths = appendUnique(c.MemberPaths, a.values)
+	}
+	return c
+}
+
+func describe(a *atom) string {
+	switch a.kind {
+	case atomIP:
+		return `"ip in"`
+	case atomMAC:
+		return `"mac in"`
+	case atomPath:
+		return `"path in"`
+	}
+	return "a condition"
+}
+
+// checkValue returns why value isn't valid for the static members of kind, or "".
+func checkValue(kind atomKind, value string) string {
+	switch kind {
+	case atomIP:
+		if net.ParseIP(value) != nil {
+			return ""
+		}
+		if _, _, err := net.ParseCIDR(value); err == nil {
+			return ""
+		}
+		if from, to, ok := strings.Cut(value, "-"); ok && net.ParseIP(from) != nil && net.ParseIP(to) != nil {
+			return ""
+		}
+		return fmt.Sprintf("%q is not an IP address, network or range", value)
+	case atomMAC:
+		if _, err := net.ParseMAC(value); err != nil {
+			return fmt.Sprintf("%q is not a MAC address", value)
+		}
+	case atomPath:
+		if !strings.HasPrefix(value, "/") {
+			return fmt.Sprintf("%q is not a policy path", value)
+		}
+	}
+	return ""
+}
+
+func appendUnique(values, more []string) []string {
+	for _, v := range more {
+		if !slices.Contains(values, v) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// keys lists the keys of m, sorted.
+func keys(m map[string]string) string {
+	list := make([]string, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,163 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package criteria
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func vmTag(value string) Condition {
+	return Condition{MemberType: "VirtualMachine", Key: "Tag", Operator: "EQUALS", Value: value}
+}
+
+func segmentTag(value string) Condition {
+	return Condition{MemberType: "Segment", Key: "Tag", Operator: "EQUALS", Value: value}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       *Criteria
+	}{
+		{
+			name:       "and binds tighter than or",
+			expression: `vm.tag[app] == web && vm.tag[env] == prod || vm.tag == db`,
+			want: &Criteria{
+				Criteria: []Criterion{
+					{Conditions: []Condition{vmTag("app|web"), vmTag("env|prod")}},
+					{Conditions: []Condition{vmTag("db")}},
+				},
+				Conjunctions: []string{"OR"},
+			},
+		},
+		{
+			name:       "parentheses distribute",
+			expression: `vm.tag == a && (vm.tag == b || vm.tag == c)`,
+			want: &Criteria{
+				Criteria: []Criterion{
+					{Conditions: []Condition{vmTag("a"), vmTag("b")}},
+					{Conditions: []Condition{vmTag("a"), vmTag("c")}},
+				},
+				Conjunctions: []string{"OR"},
+			},
+		},
+		{
+			name:       "member types ANDed",
+			expression: `vm.tag == a && segment.tag[env] == prod`,
+			want: &Criteria{
+				Criteria: []Criterion{
+					{Conditions: []Condition{vmTag("a")}},
+					{Conditions: []Condition{segmentTag("env|prod")}},
+				},
+				Conjunctions: []string{"AND"},
+			},
+		},
+		{
+			name:       "static members merged",
+			expression: `ip in 10.0.0.1 || vm.name contains web || ip in [10.0.0.0/24, 10.0.0.1]`,
+			want: &Criteria{
+				Criteria: []Criterion{
+					{IPAddresses: []string{"10.0.0.1", "10.0.0.0/24"}},
+					{Conditions: []Condition{{MemberType: "VirtualMachine", Key: "Name", Operator: "CONTAINS",
+						Value: "web"}}},
+				},
+				Conjunctions: []string{"OR"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Compile(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compile() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// repeat joins n expressions made by f with sep.
+func repeat(n int, sep string, f func(i int) string) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = f(i)
+	}
+	return strings.Join(parts, sep)
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		line       int
+		column     int
+		msg        string
+	}{
+		{"empty", "  ", 1, 3, "empty expression"},
+		{"unknown member type", `vm.tag == a || host.tag == b`, 1, 16, "unknown member type"},
+		{"unknown attribute", "vm.tag == a &&\n  vm.color == red", 2, 6, "unknown attribute"},
+		{"attribute not allowed", `segment.name == a`, 1, 1, "can't be selected by name"},
+		{"unterminated string", `vm.tag == "a`, 1, 11, "unterminated string"},
+		{"missing operator", `vm.tag a`, 1, 8, "expected ==, !="},
+		{"missing parenthesis", `(vm.tag == a`, 1, 13, `expected ")"`},
+		{"trailing token", `vm.tag == a b`, 1, 13, "expected && or ||"},
+		{"invalid IP", `ip in [10.0.0.1, 10.0.0.300]`, 1, 18, "not an IP address"},
+		{"static members ANDed", `vm.tag == a && ip in 10.0.0.1`, 1, 16, "combined with other criteria by ||"},
+		{"ipset ANDed with vm", `vm.tag == a && ipset.tag == b`, 1, 16, "only by ||"},
+		{"vm ANDed with ipset", `ipset.tag == b && vm.tag == a`, 1, 19, "only by ||"},
+		{
+			name:       "too many criteria",
+			expression: repeat(MaxCriteria+1, " || ", func(i int) string { return fmt.Sprintf("vm.tag == t%d", i) }),
+			line:       1,
+			column:     1,
+			msg:        "a group has at most 5",
+		},
+		{
+			name:       "too many conditions",
+			expression: repeat(MaxConditions+1, " && ", func(i int) string { return fmt.Sprintf("vm.tag == t%d", i) }),
+			line:       1,
+			column:     246, // the 16th condition
+			msg:        "at most 15 conditions",
+		},
+		{
+			name: "expansion too large",
+			expression: repeat(7, " && ", func(i int) string {
+				return fmt.Sprintf("(vm.tag == a%d || vm.tag == b%d)", i, i)
+			}),
+			line:   1,
+			column: 2,
+			msg:    "more than 64 alternatives",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.expression)
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("Compile() error = %v, want an *Error", err)
+			}
+			if e.Line != tt.line || e.Column != tt.column || !strings.Contains(e.Msg, tt.msg) {
+				t.Errorf("Compile() error = %v, want line %d, column %d: %s", err, tt.line, tt.column, tt.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package criteria
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Error is an error in an expression, at a position given from 1.
+type Error struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF      tokenKind = iota
+	tokenWord               // identifier, keyword or unquoted value
+	tokenString             // quoted value
+	tokenAnd                // &&
+	tokenOr                 // ||
+	tokenEq                 // ==
+	tokenNe                 // !=
+	tokenLParen             // (
+	tokenRParen             // )
+	tokenLBracket           // [
+	tokenRBracket           // ]
+	tokenComma              // ,
+)
+
+type pos struct {
+	line, column int
+}
+
+func (p pos) errorf(format string, args ...interface{}) *Error {
+	return &Error{Line: p.line, Column: p.column, Msg: fmt.Sprintf(format, args...)}
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  pos
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// isWordRune tells whether r may appear in an unquoted word, which covers names such as vm.tag and
+// values such as 10.0.0.0/24, 10.0.0.1-10.0.0.9 or 00:50:56:aa:bb:cc.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.:/-*", r)
+}
+
+// operators are the punctuation tokens.
+var operators = map[string]tokenKind{
+	"&&": tokenAnd, "||": tokenOr, "==": tokenEq, "!=": tokenNe,
+	"(": tokenLParen, ")": tokenRParen, "[": tokenLBracket, "]": tokenRBracket, ",": tokenComma,
+}
+
+// lex splits src into tokens.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	p := pos{line: 1, column: 1}
+	advance := func(n int) {
+		for i := 0; i < n; i++ {
+			if runes[0] == '\n' {
+				p.line++
+				p.column = 1
+			} else {
+				p.column++
+			}
+			runes = runes[1:]
+		}
+	}
+
+	for len(runes) > 0 {
+		r := runes[0]
+		start := p
+		switch {
+		case unicode.IsSpace(r):
+			advance(1)
+		case r == '"':
+			var b strings.Builder
+			advance(1)
+			for {
+				if len(runes) == 0 || runes[0] == '\n' {
+					return nil, start.errorf("unterminated string")
+				}
+				c := runes[0]
+				if c == '"' {
+					advance(1)
+					break
+				}
+				if c == '\\' && len(runes) > 1 && (runes[1] == '"' || runes[1] == '\\') {
+					c = runes[1]
+					advance(1)
+				}
+				b.WriteRune(c)
+				advance(1)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: b.String(), pos: start})
+		case isWordRune(r):
+			n := 0
+			for n < len(runes) && isWordRune(runes[n]) {
+				n++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: string(runes[:n]), pos: start})
+			advance(n)
+		default:
+			text := string(r)
+			if len(runes) > 1 {
+				if kind, ok := operators[string(runes[:2])]; ok {
+					tokens = append(tokens, token{kind: kind, text: string(runes[:2]), pos: start})
+					advance(2)
+					continue
+				}
+			}
+			kind, ok := operators[text]
+			if !ok {
+				return nil, start.errorf("unexpected character %q", r)
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: start})
+			advance(1)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: p}), nil
+}
@@ -0,0 +1,242 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package criteria
+
+import (
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// node is a node of the syntax tree: an or, an and or an atom.
+type node interface{}
+
+type or []node
+
+type and []node
+
+// atomKind tells what an atom selects.
+type atomKind int
+
+const (
+	atomCondition atomKind = iota // member.attribute op value
+	atomIP                        // ip in [...]
+	atomMAC                       // mac in [...]
+	atomPath                      // path in [...]
+)
+
+// atom is a leaf of the syntax tree.
+type atom struct {
+	kind      atomKind
+	pos       pos
+	condition Condition
+	values    []string
+}
+
+type parser struct {
+	tokens []token
+}
+
+func (p *parser) peek() token {
+	return p.tokens[0]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[0]
+	if t.kind != tokenEOF {
+		p.tokens = p.tokens[1:]
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, t.pos.errorf("expected %s, found %s", what, t)
+	}
+	return t, nil
+}
+
+// parseOr parses a || b || ...
+func (p *parser) parseOr() (node, error) {
+	var terms or
+	for {
+		term, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+		if p.peek().kind != tokenOr {
+			break
+		}
+		p.next()
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+// parseAnd parses a && b && ...
+func (p *parser) parseAnd() (node, error) {
+	var factors and
+	for {
+		factor, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		factors = append(factors, factor)
+		if p.peek().kind != tokenAnd {
+			break
+		}
+		p.next()
+	}
+	if len(factors) == 1 {
+		return factors[0], nil
+	}
+	return factors, nil
+}
+
+// parseFactor parses a parenthesized expression or an atom.
+func (p *parser) parseFactor() (node, error) {
+	if p.peek().kind == tokenLParen {
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return p.parseAtom()
+}
+
+// parseAtom parses ip|mac|path in values, or member.attribute[scope] op value.
+func (p *parser) parseAtom() (node, error) {
+	t, err := p.expect(tokenWord, "a condition such as vm.tag[scope] == \"value\" or ip in 10.0.0.0/8")
+	if err != nil {
+		return nil, err
+	}
+	listKinds := map[string]atomKind{"ip": atomIP, "mac": atomMAC, "path": atomPath}
+	if kind, ok := listKinds[strings.ToLower(t.text)]; ok {
+		if in := p.next(); in.kind != tokenWord || strings.ToLower(in.text) != "in" {
+			return nil, in.pos.errorf(`expected "in", found %s`, in)
+		}
+		values, err := p.parseValues(kind)
+		if err != nil {
+			return nil, err
+		}
+		return &atom{kind: kind, pos: t.pos, values: values}, nil
+	}
+
+	condition, err := p.parseCondition(t)
+	if err != nil {
+		return nil, err
+	}
+	return &atom{kind: atomCondition, pos: t.pos, condition: condition}, nil
+}
+
+func (p *parser) parseCondition(t token) (Condition, error) {
+	var c Condition
+	dot := strings.IndexByte(t.text, '.')
+	if dot < 0 {
+		return c, t.pos.errorf("expected member.attribute, such as vm.tag or vm.name, found %s", t)
+	}
+	member, attribute := strings.ToLower(t.text[:dot]), strings.ToLower(t.text[dot+1:])
+	memberType, ok := memberTypes[member]
+	if !ok {
+		return c, t.pos.errorf("unknown member type %q, expected one of %s", member, keys(memberTypes))
+	}
+	key, ok := conditionKeys[attribute]
+	if !ok {
+		attrPos := pos{line: t.pos.line, column: t.pos.column + dot + 1}
+		return c, attrPos.errorf("unknown attribute %q, expected one of %s", attribute, keys(conditionKeys))
+	}
+	if !slices.Contains(allowedAttributes[memberType], attribute) {
+		return c, t.pos.errorf("%s members can't be selected by %s, only by %s", member, attribute,
+			strings.Join(allowedAttributes[memberType], ", "))
+	}
+
+	scope, hasScope := "", false
+	if key == "Tag" && p.peek().kind == tokenLBracket {
+		p.next()
+		s := p.next()
+		if s.kind != tokenWord && s.kind != tokenString {
+			return c, s.pos.errorf("expected a tag scope, found %s", s)
+		}
+		scope, hasScope = s.text, true
+		if _, err := p.expect(tokenRBracket, `"]"`); err != nil {
+			return c, err
+		}
+	}
+
+	op := p.next()
+	var operator string
+	switch op.kind {
+	case tokenEq:
+		operator = "EQUALS"
+	case tokenNe:
+		operator = "NOTEQUALS"
+	case tokenWord:
+		operator = wordOperators[strings.ToLower(op.text)]
+	}
+	if operator == "" {
+		return c, op.pos.errorf("expected ==, !=, contains, startswith or endswith, found %s", op)
+	}
+
+	v := p.next()
+	if v.kind != tokenString && v.kind != tokenWord {
+		return c, v.pos.errorf("expected a value, found %s", v)
+	}
+	value := v.text
+	if hasScope {
+		value = scope + "|" + value
+	}
+	return Condition{MemberType: memberType, Key: key, Operator: operator, Value: value}, nil
+}
+
+// parseValues parses a value or a bracketed list of values, checked for kind.
+func (p *parser) parseValues(kind atomKind) ([]string, error) {
+	var values []string
+	add := func() error {
+		v := p.next()
+		if v.kind != tokenString && v.kind != tokenWord {
+			return v.pos.errorf("expected a value, found %s", v)
+		}
+		if err := checkValue(kind, v.text); err != "" {
+			return v.pos.errorf("%s", err)
+		}
+		values = append(values, v.text)
+		return nil
+	}
+	if p.peek().kind != tokenLBracket {
+		return values, add()
+	}
+	p.next()
+	for {
+		if err := add(); err != nil {
+			return nil, err
+		}
+		t := p.next()
+		if t.kind == tokenRBracket {
+			return values, nil
+		}
+		if t.kind != tokenComma {
+			return nil, t.pos.errorf(`expected "," or "]", found %s`, t)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfw
+
+import (
+	"fmt"
+)
+
+// Finding kinds.
+const (
+	// Shadowed rules never match: an earlier rule with another action takes all their flows.
+	Shadowed = "shadowed"
+	// Redundant rules never change the outcome: an earlier rule with the same action takes all
+	// their flows.
+	Redundant = "redundant"
+	// AnyAnyAllow rules allow any service from any source to any destination.
+	AnyAnyAllow = "any-any-allow"
+)
+
+// Finding is a problem found in a rule list.
+type Finding struct {
+	Kind string
+	Rule string
+	// By is the earlier rule covering Rule, for shadowed and redundant rules.
+	By string
+}
+
+func (f Finding) String() string {
+	switch f.Kind {
+	case Shadowed:
+		return fmt.Sprintf("rule %s is shadowed by rule %s, which takes all its flows with another action", f.Rule, f.By)
+	case Redundant:
+		return fmt.Sprintf("rule %s is redundant: rule %s already takes all its flows with the same action", f.Rule, f.By)
+	}
+	return fmt.Sprintf("rule %s allows any service from any source to any destination", f.Rule)
+}
+
+// Analyze finds the shadowed, redundant and any-any-allow rules of a list of rules, in evaluation
+// order, of a policy applied to scopes. Coverage is decided from the rules alone: a group only
+// covers itself, and an IP address, network or range the addresses in it. rules must stop before
+// the first rule with unknown values, which could take the flows of the rules after it.
+func Analyze(rules []Rule, scopes []string) []Finding {
+	var findings []Finding
+	for i, r := range rules {
+		if r.Disabled {
+			continue
+		}
+		if r.Action == "ALLOW" && isAny(r.SourceGroups) && isAny(r.DestinationGroups) && isAny(r.Services) &&
+			isAny(r.Profiles) && isAny(effectiveScopes(r, scopes)) {
+			findings = append(findings, Finding{Kind: AnyAnyAllow, Rule: r.Name})
+		}
+		for _, earlier := range rules[:i] {
+			if earlier.Disabled || !covers(earlier, r, scopes) {
+				continue
+			}
+			kind := Shadowed
+			if earlier.Action == r.Action {
+				kind = Redundant
+			}
+			findings = append(findings, Finding{Kind: kind, Rule: r.Name, By: earlier.Name})
+			break
+		}
+	}
+	return findings
+}
+
+// covers tells whether every flow matching b matches a.
+func covers(a, b Rule, scopes []string) bool {
+	if a.Action == "JUMP_TO_APPLICATION" || b.Action == "JUMP_TO_APPLICATION" {
+		return false
+	}
+	return coversEndpoints(a.SourceGroups, a.SourcesExcluded, b.SourceGroups, b.SourcesExcluded) &&
+		coversEndpoints(a.DestinationGroups, a.DestinationsExcluded, b.DestinationGroups, b.DestinationsExcluded) &&
+		coversSet(a.Services, b.Services) &&
+		coversSet(a.Profiles, b.Profiles) &&
+		coversSet(effectiveScopes(a, scopes), effectiveScopes(b, scopes)) &&
+		coversValue(a.Direction, b.Direction, "IN_OUT") &&
+		coversValue(a.IPVersion, b.IPVersion, "IPV4_IPV6")
+}
+
+func effectiveScopes(r Rule, scopes []string) []string {
+	if isAny(r.Scopes) {
+		return scopes
+	}
+	return r.Scopes
+}
+
+func coversEndpoints(a []string, aExcluded bool, b []string, bExcluded bool) bool {
+	switch {
+	case isAny(a) && !aExcluded:
+		return true
+	case aExcluded && bExcluded:
+		// Everything but a covers everything but b when b excludes more.
+		return coversSet(b, a)
+	case aExcluded || bExcluded:
+		return false
+	}
+	return coversSet(a, b)
+}
+
+// coversSet tells whether the members of b are all members of a.
+func coversSet(a, b []string) bool {
+	if isAny(a) {
+		return true
+	}
+	if isAny(b) {
+		return false
+	}
+	for _, member := range b {
+		if !coversMember(a, member) {
+			return false
+		}
+	}
+	return true
+}
+
+func coversMember(a []string, member string) bool {
+	for _, m := range a {
+		if m == member || addressCovers(m, member) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressCovers tells whether the IP address, network or range a includes all of b.
+func addressCovers(a, b string) bool {
+	low, high, ok := addressBounds(b)
+	if !ok {
+		return false
+	}
+	lowIn, ok1 := matchAddress(a, low)
+	highIn, ok2 := matchAddress(a, high)
+	return ok1 && ok2 && lowIn && highIn
+}
+
+func coversValue(a, b, all string) bool {
+	return a == "" || a == all || a == b
+}
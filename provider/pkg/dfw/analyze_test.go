@@ -0,0 +1,118 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfw
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	const web, db = "/infra/domains/default/groups/web", "/infra/domains/default/groups/db"
+	https, ssh := "/infra/services/HTTPS", "/infra/services/SSH"
+	tests := []struct {
+		name   string
+		rules  []Rule
+		scopes []string
+		want   []Finding
+	}{
+		{
+			name: "shadowed by a broader rule",
+			rules: []Rule{
+				{Name: "drop-web", Action: "DROP", SourceGroups: []string{web}},
+				{Name: "web-https", Action: "ALLOW", SourceGroups: []string{web}, Services: []string{https}},
+			},
+			want: []Finding{{Kind: Shadowed, Rule: "web-https", By: "drop-web"}},
+		},
+		{
+			name: "redundant within a network",
+			rules: []Rule{
+				{Name: "net", Action: "ALLOW", SourceGroups: []string{"10.0.0.0/16"}, Services: []string{https}},
+				{Name: "host", Action: "ALLOW", SourceGroups: []string{"10.0.1.5"}, Services: []string{https}},
+				{Name: "range", Action: "ALLOW", SourceGroups: []string{"10.0.2.1-10.0.2.9"}, Services: []string{https}},
+				{Name: "outside", Action: "ALLOW", SourceGroups: []string{"10.1.0.1"}, Services: []string{https}},
+			},
+			want: []Finding{
+				{Kind: Redundant, Rule: "host", By: "net"},
+				{Kind: Redundant, Rule: "range", By: "net"},
+			},
+		},
+		{
+			name: "narrower earlier rules",
+			rules: []Rule{
+				{Name: "web-https", Action: "ALLOW", SourceGroups: []string{web}, Services: []string{https}},
+				{Name: "web", Action: "DROP", SourceGroups: []string{web}, Services: []string{https, ssh}},
+				{Name: "in", Action: "DROP", SourceGroups: []string{db}, Direction: "IN"},
+				{Name: "in-out", Action: "DROP", SourceGroups: []string{db}},
+				{Name: "v4", Action: "DROP", DestinationGroups: []string{db}, IPVersion: "IPV4"},
+				{Name: "all", Action: "DROP", DestinationGroups: []string{db}},
+			},
+		},
+		{
+			name: "exclusions",
+			rules: []Rule{
+				{Name: "not-web", Action: "DROP", SourceGroups: []string{web}, SourcesExcluded: true},
+				{Name: "not-web-db", Action: "DROP", SourceGroups: []string{web, db}, SourcesExcluded: true},
+				{Name: "db", Action: "ALLOW", SourceGroups: []string{db}},
+				{Name: "web", Action: "ALLOW", SourceGroups: []string{web}},
+			},
+			// Groups are opaque: db may overlap web, so not-web doesn't cover it.
+			want: []Finding{{Kind: Redundant, Rule: "not-web-db", By: "not-web"}},
+		},
+		{
+			name: "disabled and jump rules cover nothing",
+			rules: []Rule{
+				{Name: "off", Action: "DROP", Services: []string{https}, Disabled: true},
+				{Name: "jump", Action: "JUMP_TO_APPLICATION", Services: []string{https}},
+				{Name: "https", Action: "DROP", Services: []string{https}},
+			},
+		},
+		{
+			name: "scopes",
+			rules: []Rule{
+				{Name: "web-scope", Action: "DROP", Services: []string{https}, Scopes: []string{web}},
+				{Name: "policy-scope", Action: "DROP", Services: []string{https}},
+				{Name: "db-scope", Action: "DROP", Services: []string{https}, Scopes: []string{db}},
+			},
+			scopes: []string{db},
+			want:   []Finding{{Kind: Redundant, Rule: "db-scope", By: "policy-scope"}},
+		},
+		{
+			name: "any-any allow",
+			rules: []Rule{
+				{Name: "any", Action: "ALLOW"},
+				{Name: "any-drop", Action: "DROP"},
+				{Name: "scoped", Action: "ALLOW", Scopes: []string{web}},
+			},
+			want: []Finding{
+				{Kind: AnyAnyAllow, Rule: "any"},
+				{Kind: Shadowed, Rule: "any-drop", By: "any"},
+				{Kind: Redundant, Rule: "scoped", By: "any"},
+			},
+		},
+		{
+			name:   "policy scope makes it not any-any",
+			rules:  []Rule{{Name: "any", Action: "ALLOW"}},
+			scopes: []string{web},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Analyze(tt.rules, tt.scopes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
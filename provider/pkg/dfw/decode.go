@@ -0,0 +1,212 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfw
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// object is a decoded JSON object whose keys are compared lowercase and without underscores, so
+// that the Pulumi (sequenceNumber) and Terraform (sequence_number) spellings are both accepted.
+type object map[string]interface{}
+
+func decodeObject(data []byte) (object, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	return normalize(m), nil
+}
+
+func normalize(m map[string]interface{}) object {
+	o := object{}
+	for k, v := range m {
+		o[strings.ToLower(strings.ReplaceAll(k, "_", ""))] = v
+	}
+	return o
+}
+
+// get returns the first of the given fields that is set.
+func (o object) get(names ...string) interface{} {
+	for _, name := range names {
+		if v, ok := o[name]; ok && v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func (o object) string(names ...string) string {
+	s, _ := o.get(names...).(string)
+	return s
+}
+
+func (o object) bool(names ...string) bool {
+	b, _ := o.get(names...).(bool)
+	return b
+}
+
+func (o object) int(names ...string) int {
+	switch v := o.get(names...).(type) {
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
+
+// objects returns a list of objects. Single objects, as Pulumi flattens the blocks of at most
+// one item, are taken as lists of one.
+func (o object) objects(names ...string) []object {
+	var list []interface{}
+	switch v := o.get(names...).(type) {
+	case []interface{}:
+		list = v
+	case map[string]interface{}:
+		list = []interface{}{v}
+	}
+	objects := make([]object, 0, len(list))
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			objects = append(objects, normalize(m))
+		}
+	}
+	return objects
+}
+
+func (o object) strings(names ...string) []string {
+	list, _ := o.get(names...).([]interface{})
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		switch v := item.(type) {
+		case string:
+			values = append(values, v)
+		case float64:
+			values = append(values, strconv.Itoa(int(v)))
+		}
+	}
+	return values
+}
+
+// name returns the display name of an object, or its NSX ID, or fallback.
+func (o object) name(fallback string) string {
+	if name := o.string("displayname"); name != "" {
+		return name
+	}
+	if id := o.string("nsxid"); id != "" {
+		return id
+	}
+	return fallback
+}
+
+// DecodePolicy decodes the inputs of a PolicySecurityPolicy or PolicyGatewayPolicy, or of the
+// matching Terraform resources, from JSON. fallback names the policy when it has no name.
+func DecodePolicy(data []byte, fallback string) (Policy, error) {
+	o, err := decodeObject(data)
+	if err != nil {
+		return Policy{}, fmt.Errorf("policy %s: %w", fallback, err)
+	}
+	p := Policy{
+		Name:           o.name(fallback),
+		Category:       o.string("category"),
+		SequenceNumber: o.int("sequencenumber"),
+		Scopes:         o.strings("scopes", "scope"),
+	}
+	for i, r := range o.objects("rules", "rule") {
+		action := r.string("action")
+		if action == "" {
+			action = "ALLOW"
+		}
+		p.Rules = append(p.Rules, Rule{
+			Name:                 r.name(fmt.Sprintf("#%d", i+1)),
+			SequenceNumber:       r.int("sequencenumber"),
+			Action:               action,
+			SourceGroups:         r.strings("sourcegroups"),
+			DestinationGroups:    r.strings("destinationgroups"),
+			Services:             r.strings("services"),
+			Scopes:               r.strings("scopes", "scope"),
+			Profiles:             r.strings("profiles"),
+			SourcesExcluded:      r.bool("sourcesexcluded"),
+			DestinationsExcluded: r.bool("destinationsexcluded"),
+			Direction:            r.string("direction"),
+			IPVersion:            r.string("ipversion"),
+			Disabled:             r.bool("disabled"),
+		})
+	}
+	return p, nil
+}
+
+// DecodeGroup decodes the inputs of a PolicyGroup, or of a nsxt_policy_group, from JSON.
+func DecodeGroup(data []byte) (Group, error) {
+	o, err := decodeObject(data)
+	if err != nil {
+		return Group{}, err
+	}
+	var g Group
+	for _, c := range o.objects("criterias", "criteria") {
+		criterion := Criterion{HasConditions: len(c.objects("conditions", "condition")) > 0}
+		for _, e := range c.objects("ipaddressexpression", "ipaddressexpressions") {
+			criterion.IPAddresses = append(criterion.IPAddresses, e.strings("ipaddresses")...)
+		}
+		for _, e := range c.objects("pathexpression", "pathexpressions") {
+			criterion.MemberPaths = append(criterion.MemberPaths, e.strings("memberpaths")...)
+		}
+		// Members selected by MAC address or external ID are resolved from the inventory too.
+		if len(c.objects("macaddressexpression", "externalidexpressions", "externalidexpression")) > 0 {
+			criterion.HasConditions = true
+		}
+		g.Criteria = append(g.Criteria, criterion)
+	}
+	for _, c := range o.objects("conjunctions", "conjunction") {
+		g.Conjunctions = append(g.Conjunctions, strings.ToUpper(c.string("operator")))
+	}
+	return g, nil
+}
+
+// DecodeService decodes the inputs of a PolicyService, or of a nsxt_policy_service, from JSON.
+func DecodeService(data []byte) (Service, error) {
+	o, err := decodeObject(data)
+	if err != nil {
+		return Service{}, err
+	}
+	var s Service
+	for _, e := range o.objects("l4portsetentries", "l4portsetentry") {
+		s.Entries = append(s.Entries, ServiceEntry{
+			Protocol:         strings.ToUpper(e.string("protocol")),
+			DestinationPorts: e.strings("destinationports"),
+			SourcePorts:      e.strings("sourceports"),
+		})
+	}
+	for _, e := range o.objects("icmpentries", "icmpentry") {
+		entry := ServiceEntry{Protocol: e.string("protocol")}
+		if t := e.get("icmptype"); t != nil {
+			n := e.int("icmptype")
+			entry.ICMPType = &n
+		}
+		s.Entries = append(s.Entries, entry)
+	}
+	for _, e := range o.objects("ipprotocolentries", "ipprotocolentry") {
+		s.Entries = append(s.Entries, ServiceEntry{Protocol: "IP", IPProtocol: e.int("protocol")})
+	}
+	return s, nil
+}
@@ -0,0 +1,382 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dfw evaluates flows against firewall policies offline, the way NSX does: policies in
+// category then sequence order, rules in sequence order, the first matching rule deciding, and
+// the default rule when none matches.
+//
+// Group membership is known for IP addresses and nested groups only. Members selected by tags or
+// other conditions, which NSX resolves from its inventory, must be given with the flow: the
+// evaluation fails when it depends on a membership that isn't. Context profiles (layer 7) aren't
+// evaluated.
+package dfw
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/exp/slices"
+)
+
+// Categories of distributed firewall policies, in evaluation order. Ethernet policies are layer 2,
+// they never match IP flows.
+var Categories = []string{"Ethernet", "Emergency", "Infrastructure", "Environment", "Application"}
+
+// GatewayCategories are the categories of gateway firewall policies, in evaluation order.
+var GatewayCategories = []string{
+	"Emergency", "SystemRules", "SharedPreRules", "LocalGatewayRules", "AutoServiceRules", "Default",
+}
+
+// Any stands for any source, destination, service or scope.
+const Any = "ANY"
+
+// Policy is a security or gateway policy.
+type Policy struct {
+	Name           string
+	Category       string
+	SequenceNumber int
+	Scopes         []string
+	Rules          []Rule
+}
+
+// Rule is a rule of a policy.
+type Rule struct {
+	Name                 string
+	SequenceNumber       int
+	Action               string // ALLOW, DROP, REJECT or JUMP_TO_APPLICATION
+	SourceGroups         []string
+	DestinationGroups    []string
+	Services             []string
+	Scopes               []string
+	Profiles             []string // context profiles, not evaluated by Evaluate
+	SourcesExcluded      bool
+	DestinationsExcluded bool
+	Direction            string // IN, OUT or IN_OUT
+	IPVersion            string // IPV4, IPV6 or IPV4_IPV6
+	Disabled             bool
+}
+
+// Group is a group: its criteria, combined by conjunctions, AND binding tighter than OR.
+type Group struct {
+	Criteria     []Criterion
+	Conjunctions []string
+}
+
+// Criterion is a criterion of a group. Conditions can't be evaluated offline: whether an endpoint
+// matches a criterion with conditions must be declared with the flow.
+type Criterion struct {
+	IPAddresses   []string
+	MemberPaths   []string
+	HasConditions bool
+}
+
+// Service is a service, matching flows matching any of its entries.
+type Service struct {
+	Entries []ServiceEntry
+}
+
+// ServiceEntry is an entry of a service. Protocol is TCP or UDP with ports, ICMPv4 or ICMPv6 with
+// an optional type, or IP with a protocol number.
+type ServiceEntry struct {
+	Protocol         string
+	DestinationPorts []string
+	SourcePorts      []string
+	ICMPType         *int
+	IPProtocol       int
+}
+
+// Config are the policies, and the groups and services they refer to by path.
+type Config struct {
+	Policies []Policy
+	Groups   map[string]Group
+	Services map[string]Service
+	// Gateway selects gateway firewall categories and scopes.
+	Gateway bool
+	// DefaultAction is the action of the default rule, ALLOW when empty.
+	DefaultAction string
+}
+
+// Verdict is the outcome of the evaluation of a flow.
+type Verdict struct {
+	Action  string
+	Policy  string
+	Rule    string
+	Default bool
+}
+
+// Evaluate returns the verdict of the rule that decides on flow.
+func Evaluate(cfg *Config, flow Flow) (Verdict, error) {
+	if err := flow.validate(); err != nil {
+		return Verdict{}, err
+	}
+	categories := Categories
+	if cfg.Gateway {
+		categories = GatewayCategories
+	}
+	order := map[string]int{}
+	for i, c := range categories {
+		order[c] = i
+	}
+	policies := make([]Policy, len(cfg.Policies))
+	copy(policies, cfg.Policies)
+	for _, p := range policies {
+		if _, ok := order[p.Category]; !ok {
+			return Verdict{}, fmt.Errorf("policy %s: unknown category %q", p.Name, p.Category)
+		}
+	}
+	sort.SliceStable(policies, func(i, j int) bool {
+		a, b := policies[i], policies[j]
+		if order[a.Category] != order[b.Category] {
+			return order[a.Category] < order[b.Category]
+		}
+		return a.SequenceNumber < b.SequenceNumber
+	})
+
+	e := &evaluator{cfg: cfg, flow: flow}
+	jumped := false
+	for _, p := range policies {
+		if p.Category == "Ethernet" && !cfg.Gateway {
+			continue
+		}
+		if jumped && p.Category != "Application" {
+			continue
+		}
+		rules := make([]Rule, len(p.Rules))
+		copy(rules, p.Rules)
+		sort.SliceStable(rules, func(i, j int) bool { return rules[i].SequenceNumber < rules[j].SequenceNumber })
+		for _, r := range rules {
+			matched, err := e.matches(p, r)
+			if err != nil {
+				return Verdict{}, fmt.Errorf("policy %s, rule %s: %w", p.Name, r.Name, err)
+			}
+			if !matched {
+				continue
+			}
+			if r.Action == "JUMP_TO_APPLICATION" {
+				jumped = true
+				break
+			}
+			return Verdict{Action: r.Action, Policy: p.Name, Rule: r.Name}, nil
+		}
+	}
+
+	action := cfg.DefaultAction
+	if action == "" {
+		action = "ALLOW"
+	}
+	return Verdict{Action: action, Default: true}, nil
+}
+
+type evaluator struct {
+	cfg  *Config
+	flow Flow
+}
+
+func (e *evaluator) matches(p Policy, r Rule) (bool, error) {
+	if r.Disabled {
+		return false, nil
+	}
+	if r.Direction != "" && r.Direction != "IN_OUT" && r.Direction != e.flow.Direction {
+		return false, nil
+	}
+	if r.IPVersion != "" && r.IPVersion != "IPV4_IPV6" && r.IPVersion != e.flow.ipVersion() {
+		return false, nil
+	}
+
+	// The scopes of the rule, or else those of the policy, tell where the rule is enforced.
+	scopes := r.Scopes
+	if isAny(scopes) {
+		scopes = p.Scopes
+	}
+	if !isAny(scopes) {
+		applies, err := e.applies(scopes)
+		if err != nil || !applies {
+			return false, err
+		}
+	}
+
+	for _, check := range []func() (bool, error){
+		func() (bool, error) {
+			return e.endpointMatches(r.SourceGroups, r.SourcesExcluded, e.flow.Source, e.flow.SourceGroups)
+		},
+		func() (bool, error) {
+			return e.endpointMatches(r.DestinationGroups, r.DestinationsExcluded, e.flow.Destination,
+				e.flow.DestinationGroups)
+		},
+		func() (bool, error) { return e.serviceMatches(r.Services) },
+	} {
+		matched, err := check()
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// applies tells whether a rule scoped to scopes is enforced where flow is evaluated: on the
+// gateway for gateway rules, else on the destination (IN) or source (OUT) workload.
+func (e *evaluator) applies(scopes []string) (bool, error) {
+	if e.cfg.Gateway {
+		return slices.Contains(scopes, e.flow.Gateway), nil
+	}
+	endpoint, declared := e.flow.Destination, e.flow.DestinationGroups
+	if e.flow.Direction == "OUT" {
+		endpoint, declared = e.flow.Source, e.flow.SourceGroups
+	}
+	return e.inAny(scopes, endpoint, declared)
+}
+
+func (e *evaluator) endpointMatches(groups []string, excluded bool, ip string, declared []string) (bool, error) {
+	if isAny(groups) {
+		return true, nil
+	}
+	in, err := e.inAny(groups, ip, declared)
+	return in != excluded, err
+}
+
+// inAny tells whether the endpoint at ip, declared to be in the groups declared, is in one of
+// groups, which may also list IP addresses, networks and ranges.
+func (e *evaluator) inAny(groups []string, ip string, declared []string) (bool, error) {
+	for _, g := range groups {
+		in, err := e.in(g, ip, declared, map[string]bool{})
+		if err != nil || in {
+			return in, err
+		}
+	}
+	return false, nil
+}
+
+func (e *evaluator) in(path, ip string, declared []string, visiting map[string]bool) (bool, error) {
+	switch {
+	case slices.Contains(declared, path):
+		return true, nil
+	case slices.Contains(declared, "!"+path):
+		return false, nil
+	}
+	group, ok := e.cfg.Groups[path]
+	if !ok {
+		if matched, ok := matchAddress(path, ip); ok {
+			return matched, nil
+		}
+		return false, fmt.Errorf("unknown group %s, its definition is needed", path)
+	}
+	if visiting[path] {
+		return false, fmt.Errorf("group %s contains itself", path)
+	}
+	visiting[path] = true
+	defer delete(visiting, path)
+
+	// Conjunctions are evaluated with AND binding tighter than OR, over true, false and unknown.
+	result, term := no, yes
+	for i, c := range group.Criteria {
+		matched, err := e.criterionMatches(c, ip, declared, visiting)
+		if err != nil {
+			return false, err
+		}
+		if i > 0 && i-1 < len(group.Conjunctions) && group.Conjunctions[i-1] == "OR" {
+			result = result.or(term)
+			term = yes
+		}
+		term = term.and(matched)
+	}
+	if len(group.Criteria) == 0 {
+		return false, nil
+	}
+	switch result.or(term) {
+	case yes:
+		return true, nil
+	case no:
+		return false, nil
+	}
+	return false, fmt.Errorf("group %s selects members by tags or other conditions, which can't be evaluated "+
+		"offline: declare whether %s belongs to it, as %s or !%s", path, ip, path, path)
+}
+
+// tristate is the outcome of a criterion: true, false or unknown.
+type tristate int
+
+const (
+	no tristate = iota
+	yes
+	unknown
+)
+
+func (a tristate) and(b tristate) tristate {
+	switch {
+	case a == no || b == no:
+		return no
+	case a == unknown || b == unknown:
+		return unknown
+	}
+	return yes
+}
+
+func (a tristate) or(b tristate) tristate {
+	switch {
+	case a == yes || b == yes:
+		return yes
+	case a == unknown || b == unknown:
+		return unknown
+	}
+	return no
+}
+
+func (e *evaluator) criterionMatches(c Criterion, ip string, declared []string,
+	visiting map[string]bool) (tristate, error) {
+	if c.HasConditions {
+		return unknown, nil
+	}
+	for _, address := range c.IPAddresses {
+		if matched, ok := matchAddress(address, ip); ok && matched {
+			return yes, nil
+		}
+	}
+	for _, member := range c.MemberPaths {
+		_, known := e.cfg.Groups[member]
+		if !known && !slices.Contains(declared, member) && !slices.Contains(declared, "!"+member) {
+			// Members other than groups (segments, ports, VMs) can only be declared.
+			continue
+		}
+		in, err := e.in(member, ip, declared, visiting)
+		if err != nil {
+			return no, err
+		}
+		if in {
+			return yes, nil
+		}
+	}
+	return no, nil
+}
+
+func (e *evaluator) serviceMatches(services []string) (bool, error) {
+	if isAny(services) {
+		return true, nil
+	}
+	for _, path := range services {
+		service, ok := e.cfg.Services[path]
+		if !ok {
+			return false, fmt.Errorf("unknown service %s, its definition is needed", path)
+		}
+		for _, entry := range service.Entries {
+			if e.flow.matchesEntry(entry) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func isAny(values []string) bool {
+	return len(values) == 0 || slices.Contains(values, Any)
+}
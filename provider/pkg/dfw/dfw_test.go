@@ -0,0 +1,332 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfw
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	icmpEcho := 8
+	groups := map[string]Group{
+		"/infra/domains/default/groups/web": {Criteria: []Criterion{{IPAddresses: []string{"10.0.1.0/24"}}}},
+		"/infra/domains/default/groups/db":  {Criteria: []Criterion{{IPAddresses: []string{"10.0.2.10-10.0.2.20"}}}},
+		"/infra/domains/default/groups/app": {
+			Criteria: []Criterion{
+				{MemberPaths: []string{"/infra/domains/default/groups/web"}},
+				{IPAddresses: []string{"10.0.1.5"}},
+			},
+			Conjunctions: []string{"AND"},
+		},
+		"/infra/domains/default/groups/tagged": {Criteria: []Criterion{{HasConditions: true}}},
+		"/infra/domains/default/groups/tagged-or-db": {
+			Criteria:     []Criterion{{HasConditions: true}, {IPAddresses: []string{"10.0.2.0/24"}}},
+			Conjunctions: []string{"OR"},
+		},
+		"/infra/domains/default/groups/tagged-and-web": {
+			Criteria:     []Criterion{{HasConditions: true}, {IPAddresses: []string{"10.0.1.0/24"}}},
+			Conjunctions: []string{"AND"},
+		},
+	}
+	services := map[string]Service{
+		"/infra/services/HTTPS": {Entries: []ServiceEntry{{Protocol: "TCP", DestinationPorts: []string{"443"}}}},
+		"/infra/services/high":  {Entries: []ServiceEntry{{Protocol: "TCP", DestinationPorts: []string{"8000-8080"}}}},
+		"/infra/services/ping":  {Entries: []ServiceEntry{{Protocol: "ICMPv4", ICMPType: &icmpEcho}}},
+		"/infra/services/gre":   {Entries: []ServiceEntry{{Protocol: "IP", IPProtocol: 47}}},
+	}
+	web, db := "/infra/domains/default/groups/web", "/infra/domains/default/groups/db"
+	flow := Flow{Source: "10.0.1.5", Destination: "10.0.2.15", Protocol: "TCP", DestinationPort: 443}
+
+	tests := []struct {
+		name          string
+		policies      []Policy
+		gateway       bool
+		defaultAction string
+		flow          Flow
+		want          Verdict
+		wantErr       string
+	}{
+		{
+			name: "category before sequence number",
+			policies: []Policy{
+				{Name: "app", Category: "Application", Rules: []Rule{{Name: "allow", Action: "ALLOW"}}},
+				{Name: "env", Category: "Environment", SequenceNumber: 10, Rules: []Rule{{Name: "drop", Action: "DROP"}}},
+			},
+			flow: flow,
+			want: Verdict{Action: "DROP", Policy: "env", Rule: "drop"},
+		},
+		{
+			name: "policy and rule sequence numbers",
+			policies: []Policy{
+				{Name: "second", Category: "Application", SequenceNumber: 2, Rules: []Rule{{Name: "a", Action: "ALLOW"}}},
+				{Name: "first", Category: "Application", SequenceNumber: 1, Rules: []Rule{
+					{Name: "later", SequenceNumber: 20, Action: "ALLOW"},
+					{Name: "earlier", SequenceNumber: 10, Action: "REJECT"},
+				}},
+			},
+			flow: flow,
+			want: Verdict{Action: "REJECT", Policy: "first", Rule: "earlier"},
+		},
+		{
+			name: "disabled rule skipped",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "off", SequenceNumber: 1, Action: "DROP", Disabled: true},
+				{Name: "on", SequenceNumber: 2, Action: "ALLOW"},
+			}}},
+			flow: flow,
+			want: Verdict{Action: "ALLOW", Policy: "p", Rule: "on"},
+		},
+		{
+			name: "direction",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "out", SequenceNumber: 1, Action: "DROP", Direction: "OUT"},
+				{Name: "in", SequenceNumber: 2, Action: "ALLOW", Direction: "IN"},
+			}}},
+			flow: flow,
+			want: Verdict{Action: "ALLOW", Policy: "p", Rule: "in"},
+		},
+		{
+			name: "IP version",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "v6", SequenceNumber: 1, Action: "DROP", IPVersion: "IPV6"},
+			}}},
+			flow: flow,
+			want: Verdict{Action: "ALLOW", Default: true},
+		},
+		{
+			name: "groups by address, range and nesting",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "other", SequenceNumber: 1, Action: "DROP", SourceGroups: []string{db}},
+				{Name: "web-db", SequenceNumber: 2, Action: "ALLOW", SourceGroups: []string{"/infra/domains/default/groups/app"},
+					DestinationGroups: []string{db}},
+			}}},
+			flow: flow,
+			want: Verdict{Action: "ALLOW", Policy: "p", Rule: "web-db"},
+		},
+		{
+			name: "raw IP addresses",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "ip", Action: "DROP", SourceGroups: []string{"10.0.1.5"}, DestinationGroups: []string{"10.0.2.0/24"}},
+			}}},
+			flow: flow,
+			want: Verdict{Action: "DROP", Policy: "p", Rule: "ip"},
+		},
+		{
+			name: "excluded sources",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "not-web", SequenceNumber: 1, Action: "DROP", SourceGroups: []string{web}, SourcesExcluded: true},
+				{Name: "not-db", SequenceNumber: 2, Action: "REJECT", SourceGroups: []string{db}, SourcesExcluded: true},
+			}}},
+			flow: flow,
+			want: Verdict{Action: "REJECT", Policy: "p", Rule: "not-db"},
+		},
+		{
+			name: "conditions need declared membership",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "tagged", Action: "DROP", DestinationGroups: []string{"/infra/domains/default/groups/tagged"}},
+			}}},
+			flow:    flow,
+			wantErr: "group /infra/domains/default/groups/tagged selects members by tags or other conditions",
+		},
+		{
+			name: "conditions decided by the other criteria",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "tagged-web", SequenceNumber: 1, Action: "REJECT",
+					DestinationGroups: []string{"/infra/domains/default/groups/tagged-and-web"}},
+				{Name: "tagged-db", SequenceNumber: 2, Action: "DROP",
+					DestinationGroups: []string{"/infra/domains/default/groups/tagged-or-db"}},
+			}}},
+			flow: flow,
+			want: Verdict{Action: "DROP", Policy: "p", Rule: "tagged-db"},
+		},
+		{
+			name: "declared non-membership",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "tagged", Action: "DROP", DestinationGroups: []string{"/infra/domains/default/groups/tagged"}},
+			}}},
+			flow: Flow{Source: "10.0.1.5", Destination: "10.0.2.15", DestinationPort: 443,
+				DestinationGroups: []string{"!/infra/domains/default/groups/tagged"}},
+			want: Verdict{Action: "ALLOW", Default: true},
+		},
+		{
+			name: "declared membership",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "tagged", Action: "DROP", DestinationGroups: []string{"/infra/domains/default/groups/tagged"}},
+			}}},
+			flow: Flow{Source: "10.0.1.5", Destination: "10.0.2.15", DestinationPort: 443,
+				DestinationGroups: []string{"/infra/domains/default/groups/tagged"}},
+			want: Verdict{Action: "DROP", Policy: "p", Rule: "tagged"},
+		},
+		{
+			name: "services",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "high", SequenceNumber: 1, Action: "DROP", Services: []string{"/infra/services/high"}},
+				{Name: "https", SequenceNumber: 2, Action: "ALLOW", Services: []string{"/infra/services/HTTPS"}},
+			}}},
+			flow: flow,
+			want: Verdict{Action: "ALLOW", Policy: "p", Rule: "https"},
+		},
+		{
+			name: "ICMP type",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "ping", Action: "ALLOW", Services: []string{"/infra/services/ping"}},
+			}}},
+			flow: Flow{Source: "10.0.1.5", Destination: "10.0.2.15", Protocol: "ICMPv4", ICMPType: &icmpEcho},
+			want: Verdict{Action: "ALLOW", Policy: "p", Rule: "ping"},
+		},
+		{
+			name: "ICMP without type",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "ping", Action: "ALLOW", Services: []string{"/infra/services/ping"}},
+			}}},
+			defaultAction: "DROP",
+			flow:          Flow{Source: "10.0.1.5", Destination: "10.0.2.15", Protocol: "ICMPv4"},
+			want:          Verdict{Action: "DROP", Default: true},
+		},
+		{
+			name: "IP protocol",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "gre", Action: "ALLOW", Services: []string{"/infra/services/gre"}},
+			}}},
+			flow: Flow{Source: "10.0.1.5", Destination: "10.0.2.15", Protocol: "47"},
+			want: Verdict{Action: "ALLOW", Policy: "p", Rule: "gre"},
+		},
+		{
+			name: "rule scope on the destination",
+			policies: []Policy{{Name: "p", Category: "Application", Scopes: []string{db}, Rules: []Rule{
+				{Name: "web-only", SequenceNumber: 1, Action: "DROP", Scopes: []string{web}},
+				{Name: "policy-scope", SequenceNumber: 2, Action: "REJECT"},
+			}}},
+			flow: flow,
+			want: Verdict{Action: "REJECT", Policy: "p", Rule: "policy-scope"},
+		},
+		{
+			name: "jump to application",
+			policies: []Policy{
+				{Name: "env", Category: "Environment", Rules: []Rule{
+					{Name: "jump", SequenceNumber: 1, Action: "JUMP_TO_APPLICATION"},
+					{Name: "drop", SequenceNumber: 2, Action: "DROP"},
+				}},
+				{Name: "app", Category: "Application", Rules: []Rule{{Name: "allow", Action: "ALLOW"}}},
+			},
+			flow: flow,
+			want: Verdict{Action: "ALLOW", Policy: "app", Rule: "allow"},
+		},
+		{
+			name: "default rule",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "db-only", Action: "ALLOW", SourceGroups: []string{db}},
+			}}},
+			defaultAction: "DROP",
+			flow:          flow,
+			want:          Verdict{Action: "DROP", Default: true},
+		},
+		{
+			name:    "gateway categories and scope",
+			gateway: true,
+			policies: []Policy{
+				{Name: "local", Category: "LocalGatewayRules", Rules: []Rule{{Name: "allow", Action: "ALLOW"}}},
+				{Name: "other", Category: "Emergency", Scopes: []string{"/infra/tier-1s/other"}, Rules: []Rule{
+					{Name: "drop", Action: "DROP"},
+				}},
+				{Name: "shared", Category: "SharedPreRules", Scopes: []string{"/infra/tier-1s/t1"}, Rules: []Rule{
+					{Name: "reject", Action: "REJECT"},
+				}},
+			},
+			flow: Flow{Source: "10.0.1.5", Destination: "10.0.2.15", DestinationPort: 443, Gateway: "/infra/tier-1s/t1"},
+			want: Verdict{Action: "REJECT", Policy: "shared", Rule: "reject"},
+		},
+		{
+			name:     "distributed category on a gateway",
+			gateway:  true,
+			policies: []Policy{{Name: "app", Category: "Application"}},
+			flow:     flow,
+			wantErr:  `unknown category "Application"`,
+		},
+		{
+			name: "unknown group",
+			policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+				{Name: "r", Action: "DROP", SourceGroups: []string{"/infra/domains/default/groups/missing"}},
+			}}},
+			flow:    flow,
+			wantErr: "unknown group",
+		},
+		{
+			name:    "mixed IP versions",
+			flow:    Flow{Source: "10.0.1.5", Destination: "fd00::1"},
+			wantErr: "same IP version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Policies: tt.policies, Groups: groups, Services: services, Gateway: tt.gateway,
+				DefaultAction: tt.defaultAction}
+			got, err := Evaluate(cfg, tt.flow)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Evaluate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateTagGroup(t *testing.T) {
+	const tagged = "/infra/domains/default/groups/tagged"
+	// The inputs of a PolicyGroup selecting VMs by tag.
+	group, err := DecodeGroup([]byte(`{"criterias": [{"conditions": [{"key": "Tag", "memberType": "VirtualMachine",
+		"operator": "EQUALS", "value": "app|web"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{
+		Policies: []Policy{{Name: "p", Category: "Application", Rules: []Rule{
+			{Name: "web", Action: "DROP", DestinationGroups: []string{tagged}},
+		}}},
+		Groups: map[string]Group{tagged: group},
+	}
+
+	tests := []struct {
+		declared []string
+		want     Verdict
+		wantErr  string
+	}{
+		{declared: nil, wantErr: "group " + tagged + " selects members by tags"},
+		{declared: []string{tagged}, want: Verdict{Action: "DROP", Policy: "p", Rule: "web"}},
+		{declared: []string{"!" + tagged}, want: Verdict{Action: "ALLOW", Default: true}},
+	}
+	for _, tt := range tests {
+		flow := Flow{Source: "10.0.1.5", Destination: "10.0.2.15", DestinationGroups: tt.declared}
+		got, err := Evaluate(cfg, flow)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Evaluate(%v) error = %v, want %q", tt.declared, err, tt.wantErr)
+			}
+		case err != nil:
+			t.Errorf("Evaluate(%v) error = %v", tt.declared, err)
+		case got != tt.want:
+			t.Errorf("Evaluate(%v) = %+v, want %+v", tt.declared, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dfw
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Flow is a flow to evaluate.
+type Flow struct {
+	Source      string
+	Destination string
+	// Protocol is TCP, UDP, ICMPv4, ICMPv6 or an IP protocol number.
+	Protocol        string
+	SourcePort      int
+	DestinationPort int
+	ICMPType        *int
+	// SourceGroups and DestinationGroups are the paths of groups the endpoints are known to belong
+	// to, or, prefixed with !, not to, for memberships that can't be evaluated offline.
+	SourceGroups      []string
+	DestinationGroups []string
+	// Direction is where the flow is evaluated: IN on the destination, OUT on the source.
+	Direction string
+	// Gateway is the path of the gateway the flow crosses, for gateway policies.
+	Gateway string
+}
+
+func (f *Flow) validate() error {
+	if net.ParseIP(f.Source) == nil {
+		return fmt.Errorf("invalid source IP address %q", f.Source)
+	}
+	if net.ParseIP(f.Destination) == nil {
+		return fmt.Errorf("invalid destination IP address %q", f.Destination)
+	}
+	if f.ipVersion() != ipVersion(f.Destination) {
+		return fmt.Errorf("source %s and destination %s aren't of the same IP version", f.Source, f.Destination)
+	}
+	if f.Direction == "" {
+		f.Direction = "IN"
+	}
+	if f.Direction != "IN" && f.Direction != "OUT" {
+		return fmt.Errorf("invalid direction %q, expected IN or OUT", f.Direction)
+	}
+	if f.Protocol == "" {
+		f.Protocol = "TCP"
+	}
+	return nil
+}
+
+func (f *Flow) ipVersion() string {
+	return ipVersion(f.Source)
+}
+
+func ipVersion(ip string) string {
+	if net.ParseIP(ip).To4() != nil {
+		return "IPV4"
+	}
+	return "IPV6"
+}
+
+// protocolNumbers are the IP protocol numbers of the protocols named in flows.
+var protocolNumbers = map[string]int{"ICMPv4": 1, "TCP": 6, "UDP": 17, "ICMPv6": 58}
+
+func (f *Flow) matchesEntry(entry ServiceEntry) bool {
+	switch strings.ToUpper(entry.Protocol) {
+	case "TCP", "UDP":
+		return strings.EqualFold(entry.Protocol, f.Protocol) &&
+			matchPorts(entry.DestinationPorts, f.DestinationPort) && matchPorts(entry.SourcePorts, f.SourcePort)
+	case "ICMPV4", "ICMPV6":
+		return strings.EqualFold(entry.Protocol, f.Protocol) &&
+			(entry.ICMPType == nil || f.ICMPType != nil && *entry.ICMPType == *f.ICMPType)
+	case "IP":
+		number, ok := protocolNumbers[f.Protocol]
+		if !ok {
+			number, _ = strconv.Atoi(f.Protocol)
+		}
+		return number == entry.IPProtocol
+	}
+	return false
+}
+
+// matchPorts tells whether port is one of ports, which hold ports and ranges such as 8000-8080.
+// No ports match any port.
+func matchPorts(ports []string, port int) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, p := range ports {
+		from, to, isRange := strings.Cut(p, "-")
+		low, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			continue
+		}
+		high := low
+		if isRange {
+			if high, err = strconv.Atoi(strings.TrimSpace(to)); err != nil {
+				continue
+			}
+		}
+		if port >= low && port <= high {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAddress tells whether ip is address, in the network or in the range address. ok is false
+// when address is none of these.
+func matchAddress(address, ip string) (matched, ok bool) {
+	target := net.ParseIP(ip)
+	if a := net.ParseIP(address); a != nil {
+		return a.Equal(target), true
+	}
+	if _, network, err := net.ParseCIDR(address); err == nil {
+		return network.Contains(target), true
+	}
+	if from, to, isRange := strings.Cut(address, "-"); isRange {
+		low, high := net.ParseIP(strings.TrimSpace(from)), net.ParseIP(strings.TrimSpace(to))
+		if low != nil && high != nil {
+			return compareIP(low, target) <= 0 && compareIP(target, high) <= 0, true
+		}
+	}
+	return false, false
+}
+
+func compareIP(a, b net.IP) int {
+	return bytes.Compare(a.To16(), b.To16())
+}
+
+// addressBounds returns the first and last addresses of an IP address, network or range.
+func addressBounds(address string) (low, high string, ok bool) {
+	if ip := net.ParseIP(address); ip != nil {
+		return address, address, true
+	}
+	if _, network, err := net.ParseCIDR(address); err == nil {
+		last := make(net.IP, len(network.IP))
+		for i := range network.IP {
+			last[i] = network.IP[i] | ^network.Mask[i]
+		}
+		return network.IP.String(), last.String(), true
+	}
+	if from, to, isRange := strings.Cut(address, "-"); isRange {
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if net.ParseIP(from) != nil && net.ParseIP(to) != nil {
+			return from, to, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,286 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/id"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/exp/slices"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+// tagOperationSize bounds the VMs a single tag operation applies a tag to, or removes it from.
+const tagOperationSize = 1000
+
+// vmSearchSize bounds the VMs looked up by a single search, keeping its URL short.
+const vmSearchSize = 100
+
+func resourceBulkVMTags(conn *nsxapi.Connection) *schema.Resource {
+	tags := tagInputSchema()
+	tags.Optional, tags.Required, tags.MinItems = false, true, 1
+	tags.Description = "Tags of the VMs. Their scopes are managed by the resource: other tags with these " +
+		"scopes are removed from the VMs, tags with other scopes are left alone"
+	tags.Elem.(*schema.Resource).Schema["scope"].Optional = false
+	tags.Elem.(*schema.Resource).Schema["scope"].Required = true
+
+	return &schema.Resource{
+		Description: "Tags many VMs at once with NSX bulk tag operations, one per tag rather than one call " +
+			"per VM. Only the scopes of the tags are managed. It can't be imported: its ID is generated, and " +
+			"NSX doesn't record which resource tagged a VM.",
+		CreateContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			if diags := applyBulkVMTags(ctx, conn, d); diags != nil {
+				return diags
+			}
+			d.SetId(id.PrefixedUniqueId("bulk-vm-tags-"))
+			return readBulkVMTags(ctx, conn, d)
+		},
+		ReadContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			return readBulkVMTags(ctx, conn, d)
+		},
+		UpdateContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			if diags := applyBulkVMTags(ctx, conn, d); diags != nil {
+				return diags
+			}
+			return readBulkVMTags(ctx, conn, d)
+		},
+		DeleteContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			c, diags := client(conn)
+			if diags != nil {
+				return diags
+			}
+			current, err := vmTags(ctx, c, stringSet(d.Get("vm_ids")))
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			wanted := expandTags(d.Get("tag").([]interface{}))
+			ops := tagOperations{}
+			for _, vm := range stringSet(d.Get("vm_ids")) {
+				for _, t := range current[vm] {
+					if slices.Contains(wanted, t) {
+						ops.remove(t, vm)
+					}
+				}
+			}
+			return diag.FromErr(ops.run(ctx, c))
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(defaultRealizationTimeout),
+			Update: schema.DefaultTimeout(defaultRealizationTimeout),
+			Delete: schema.DefaultTimeout(defaultRealizationTimeout),
+		},
+		Schema: map[string]*schema.Schema{
+			"tag": tags,
+			"vm_ids": {
+				Type:        schema.TypeSet,
+				Required:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "External IDs of the VMs to tag, as returned by getPolicyVms",
+			},
+		},
+	}
+}
+
+// vmTags returns the tags of the VMs with the given external IDs, by external ID. VMs unknown to
+// NSX are left out.
+func vmTags(ctx context.Context, c *nsxapi.Client, ids []string) (map[string][]tag, error) {
+	tags := make(map[string][]tag, len(ids))
+	for len(ids) > 0 {
+		var batch []string
+		batch, ids = split(ids, vmSearchSize)
+		quoted := make([]string, 0, len(batch))
+		for _, id := range batch {
+			quoted = append(quoted, quoteSearch(id))
+		}
+		query := searchQuery("VirtualMachine", nil, "", "", "external_id:("+strings.Join(quoted, " OR ")+")")
+		vms, err := c.List(ctx, c.PolicyAPI("/search/query?query="+url.QueryEscape(query)))
+		if err != nil {
+			return nil, err
+		}
+		for _, vm := range vms {
+			tags[stringField(vm, "external_id")] = tagsOf(vm)
+		}
+	}
+	return tags, nil
+}
+
+// applyBulkVMTags gives the VMs the tags of the resource, and takes them away from the VMs no
+// longer listed. Within the managed scopes, those of the tags now and before, the VMs end up
+// with the tags of the resource only.
+func applyBulkVMTags(ctx context.Context, conn *nsxapi.Connection, d *schema.ResourceData) diag.Diagnostics {
+	c, diags := client(conn)
+	if diags != nil {
+		return diags
+	}
+	oldTags, newTags := d.GetChange("tag")
+	oldVMs, newVMs := d.GetChange("vm_ids")
+	vms := stringSet(newVMs)
+	for _, vm := range stringSet(oldVMs) {
+		if !slices.Contains(vms, vm) {
+			vms = append(vms, vm)
+		}
+	}
+	current, err := vmTags(ctx, c, vms)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	previous, wanted := expandTags(oldTags.([]interface{})), expandTags(newTags.([]interface{}))
+	scopes := map[string]bool{}
+	for _, t := range append(append([]tag(nil), previous...), wanted...) {
+		scopes[t.Scope] = true
+	}
+
+	ops := tagOperations{}
+	listed := map[string]bool{}
+	for _, vm := range stringSet(newVMs) {
+		listed[vm] = true
+		have, ok := current[vm]
+		if !ok {
+			return diag.Errorf("VM %s not found: vmIds are the external IDs of VMs known to NSX", vm)
+		}
+		for _, t := range wanted {
+			if !slices.Contains(have, t) {
+				ops.apply(t, vm)
+			}
+		}
+		for _, t := range have {
+			if scopes[t.Scope] && !slices.Contains(wanted, t) {
+				ops.remove(t, vm)
+			}
+		}
+	}
+	for _, vm := range stringSet(oldVMs) {
+		if listed[vm] {
+			continue
+		}
+		for _, t := range current[vm] {
+			if slices.Contains(previous, t) || slices.Contains(wanted, t) {
+				ops.remove(t, vm)
+			}
+		}
+	}
+	return diag.FromErr(ops.run(ctx, c))
+}
+
+// readBulkVMTags keeps the VMs whose tags, within the scopes of the resource, are exactly its
+// tags. The others, and the VMs that are gone, show up as VMs to tag.
+func readBulkVMTags(ctx context.Context, conn *nsxapi.Connection, d *schema.ResourceData) diag.Diagnostics {
+	c, diags := client(conn)
+	if diags != nil {
+		return diags
+	}
+	current, err := vmTags(ctx, c, stringSet(d.Get("vm_ids")))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	wanted := expandTags(d.Get("tag").([]interface{}))
+	scopes := map[string]bool{}
+	for _, t := range wanted {
+		scopes[t.Scope] = true
+	}
+
+	var tagged []string
+	for _, vm := range stringSet(d.Get("vm_ids")) {
+		have, ok := current[vm]
+		if !ok {
+			continue
+		}
+		var managed []tag
+		for _, t := range have {
+			if scopes[t.Scope] {
+				managed = append(managed, t)
+			}
+		}
+		if sameTags(managed, wanted) {
+			tagged = append(tagged, vm)
+		}
+	}
+	return diag.FromErr(SetAll(d, map[string]interface{}{"vm_ids": tagged}))
+}
+
+// sameTags tells whether a and b hold the same tags, regardless of order and duplicates.
+func sameTags(a, b []tag) bool {
+	for _, t := range a {
+		if !slices.Contains(b, t) {
+			return false
+		}
+	}
+	for _, t := range b {
+		if !slices.Contains(a, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// tagOperations collects, for each tag, the VMs to apply it to and to remove it from.
+type tagOperations map[tag]*nsxapi.TagOperation
+
+func (ops tagOperations) get(t tag) *nsxapi.TagOperation {
+	op, ok := ops[t]
+	if !ok {
+		op = &nsxapi.TagOperation{ResourceType: "VirtualMachine", Scope: t.Scope, Tag: t.Tag}
+		ops[t] = op
+	}
+	return op
+}
+
+func (ops tagOperations) apply(t tag, vm string) {
+	op := ops.get(t)
+	op.ApplyTo = append(op.ApplyTo, vm)
+}
+
+func (ops tagOperations) remove(t tag, vm string) {
+	op := ops.get(t)
+	op.RemoveFrom = append(op.RemoveFrom, vm)
+}
+
+// run runs the operations one after the other, in batches of at most tagOperationSize VMs.
+func (ops tagOperations) run(ctx context.Context, c *nsxapi.Client) error {
+	keys := make([]tag, 0, len(ops))
+	for t := range ops {
+		keys = append(keys, t)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Scope < keys[j].Scope || keys[i].Scope == keys[j].Scope && keys[i].Tag < keys[j].Tag
+	})
+	for _, t := range keys {
+		op := ops[t]
+		for len(op.ApplyTo) > 0 || len(op.RemoveFrom) > 0 {
+			batch := *op
+			batch.ApplyTo, op.ApplyTo = split(op.ApplyTo, tagOperationSize)
+			batch.RemoveFrom, op.RemoveFrom = split(op.RemoveFrom, tagOperationSize)
+			if err := c.RunTagOperation(ctx, id.PrefixedUniqueId("pulumi-tags-"), batch); err != nil {
+				return fmt.Errorf("cannot tag VMs with %s:%s: %w", t.Scope, t.Tag, err)
+			}
+		}
+	}
+	return nil
+}
+
+// split returns the first n values, and the rest.
+func split(values []string, n int) ([]string, []string) {
+	if len(values) <= n {
+		return values, nil
+	}
+	return values[:n], values[n:]
+}
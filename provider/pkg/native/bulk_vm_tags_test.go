@@ -0,0 +1,75 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestReadBulkVMTags(t *testing.T) {
+	quoted := regexp.MustCompile(`"(vm-\d+)"`)
+	var searches int
+	conn := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		if !strings.HasPrefix(query, "resource_type:VirtualMachine AND (external_id:(") {
+			t.Errorf("query = %q, want a search by external ID", query)
+		}
+		searches++
+		// vm-0 has other tags, vm-1 isn't known to NSX.
+		var vms []interface{}
+		for _, m := range quoted.FindAllStringSubmatch(query, -1) {
+			tags := []interface{}{map[string]interface{}{"scope": "env", "tag": "prod"}}
+			switch m[1] {
+			case "vm-0":
+				tags = []interface{}{map[string]interface{}{"scope": "env", "tag": "dev"}}
+			case "vm-1":
+				continue
+			}
+			vms = append(vms, map[string]interface{}{"external_id": m[1], "tags": tags})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": vms, "result_count": len(vms)})
+	})
+	res := resourceBulkVMTags(conn)
+	ids := make([]interface{}, 0, 150)
+	for i := 0; i < 150; i++ {
+		ids = append(ids, fmt.Sprintf("vm-%d", i))
+	}
+	d := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{
+		"tag":    []interface{}{map[string]interface{}{"scope": "env", "tag": "prod"}},
+		"vm_ids": ids,
+	})
+	d.SetId("bulk-vm-tags-test")
+
+	if diags := res.ReadContext(context.Background(), d, nil); diags.HasError() {
+		t.Fatal(diags)
+	}
+	if searches != 2 {
+		t.Errorf("searches = %d, want 2 batches", searches)
+	}
+	tagged := stringSet(d.Get("vm_ids"))
+	sort.Strings(tagged)
+	if len(tagged) != 148 || tagged[0] != "vm-10" {
+		t.Errorf("vm_ids = %d VMs from %v, want 148 without vm-0 and vm-1", len(tagged), tagged[:1])
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+func dataSourceCapabilities(conn *nsxapi.Connection) *schema.Resource {
+	return &schema.Resource{
+		Description: "Describes what the NSX manager the provider is connected to supports. The result is " +
+			"computed once per provider instance.",
+		ReadContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			caps, err := conn.Capabilities(ctx)
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			id := caps.Version
+			if id == "" {
+				id = "unknown"
+			}
+			d.SetId(id)
+			return diag.FromErr(SetAll(d, map[string]interface{}{
+				"version":            caps.Version,
+				"build":              caps.Build,
+				"deployment_type":    caps.DeploymentType,
+				"licensed_features":  caps.LicensedFeatures,
+				"enforcement_points": caps.EnforcementPoints,
+				"policy_api":         caps.PolicyAPI,
+				"manager_api":        caps.ManagerAPI,
+				"projects":           caps.Projects,
+				"evpn":               caps.EVPN,
+				"ids":                caps.IDS,
+			}))
+		},
+		Schema: map[string]*schema.Schema{
+			"version": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Product version of the manager, such as 4.1.2.0.0.22589037, empty if unknown",
+			},
+			"build": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Build number of the manager",
+			},
+			"deployment_type": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "LocalManager, GlobalManager or VMC",
+			},
+			"licensed_features": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Features enabled by the licenses of the manager",
+			},
+			"enforcement_points": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Policy paths of the enforcement points of the default site",
+			},
+			"policy_api": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the Policy API is available",
+			},
+			"manager_api": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the Manager API is available, it never is on VMC",
+			},
+			"projects": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether projects (multi-tenancy) are supported",
+			},
+			"evpn": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether EVPN is supported",
+			},
+			"ids": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether IDS/IPS is supported and licensed",
+			},
+		},
+	}
+}
+
+// SetAll sets several fields of d.
+func SetAll(d *schema.ResourceData, values map[string]interface{}) error {
+	for k, v := range values {
+		if err := d.Set(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/dfw"
+)
+
+// dataSourceEvaluateFlow evaluates a flow against policies given as JSON, without calling NSX.
+func dataSourceEvaluateFlow() *schema.Resource {
+	optionalStrings := func(description string) *schema.Schema {
+		return &schema.Schema{
+			Type:        schema.TypeList,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: description,
+		}
+	}
+	optionalStringMap := func(description string) *schema.Schema {
+		return &schema.Schema{
+			Type:        schema.TypeMap,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: description,
+		}
+	}
+	return &schema.Resource{
+		Description: "Tells which rule of a set of firewall policies decides on a flow, and its action, " +
+			"without calling NSX.",
+		ReadContext: func(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			cfg, err := flowConfig(d)
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			// GetOk can't tell type 0 (echo reply) from no type, hence the -1 default.
+			var icmpType *int
+			if n := d.Get("icmp_type").(int); n >= 0 {
+				icmpType = &n
+			}
+			flow := dfw.Flow{
+				Source:            d.Get("source_ip").(string),
+				Destination:       d.Get("destination_ip").(string),
+				Protocol:          d.Get("protocol").(string),
+				SourcePort:        d.Get("source_port").(int),
+				DestinationPort:   d.Get("destination_port").(int),
+				ICMPType:          icmpType,
+				SourceGroups:      stringList(d.Get("source_groups")),
+				DestinationGroups: stringList(d.Get("destination_groups")),
+				Direction:         d.Get("direction").(string),
+				Gateway:           d.Get("gateway_path").(string),
+			}
+			verdict, err := dfw.Evaluate(cfg, flow)
+			if err != nil {
+				return diag.FromErr(err)
+			}
+
+			d.SetId(fmt.Sprintf("%s>%s:%s/%d", flow.Source, flow.Destination, flow.Protocol, flow.DestinationPort))
+			return diag.FromErr(SetAll(d, map[string]interface{}{
+				"action":     verdict.Action,
+				"policy":     verdict.Policy,
+				"rule":       verdict.Rule,
+				"is_default": verdict.Default,
+			}))
+		},
+		Schema: map[string]*schema.Schema{
+			"security_policies": optionalStrings("Inputs of distributed firewall policies (PolicySecurityPolicy), " +
+				"as JSON"),
+			"gateway_policies": optionalStrings("Inputs of gateway firewall policies (PolicyGatewayPolicy), as JSON"),
+			"groups": optionalStringMap("Inputs of the groups (PolicyGroup) the policies refer to, as JSON, " +
+				"by path"),
+			"services": optionalStringMap("Inputs of the services (PolicyService) the policies refer to, as JSON, " +
+				"by path"),
+			"default_action": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "ALLOW",
+				Description:  "Action of the default rule",
+				ValidateFunc: validation.StringInSlice([]string{"ALLOW", "DROP", "REJECT"}, false),
+			},
+			"source_ip": {
+				Type:         schema.TypeString,
+				Required:     true,
+				Description:  "Source IP address of the flow",
+				ValidateFunc: validation.IsIPAddress,
+			},
+			"destination_ip": {
+				Type:         schema.TypeString,
+				Required:     true,
+				Description:  "Destination IP address of the flow",
+				ValidateFunc: validation.IsIPAddress,
+			},
+			"protocol": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "TCP",
+				Description: "TCP, UDP, ICMPv4, ICMPv6 or an IP protocol number",
+			},
+			"source_port": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "Source port of the flow",
+			},
+			"destination_port": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "Destination port of the flow",
+			},
+			"icmp_type": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      -1,
+				Description:  "ICMP type of the flow, none when -1",
+				ValidateFunc: validation.IntBetween(-1, 255),
+			},
+			"source_groups": optionalStrings("Paths of groups the source belongs to through tags or other conditions, " +
+				"which can't be evaluated offline, or prefixed with ! doesn't belong to"),
+			"destination_groups": optionalStrings("Paths of groups the destination belongs to through tags or other " +
+				"conditions, which can't be evaluated offline, or prefixed with ! doesn't belong to"),
+			"direction": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "IN",
+				Description:  "Where the flow is evaluated: IN on the destination, OUT on the source",
+				ValidateFunc: validation.StringInSlice([]string{"IN", "OUT"}, false),
+			},
+			"gateway_path": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Description: "Path of the gateway the flow crosses: the gateway policies are evaluated instead of " +
+					"the distributed ones",
+			},
+			"action": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "ALLOW, DROP or REJECT",
+			},
+			"policy": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Display name of the policy of the deciding rule, empty for the default rule",
+			},
+			"rule": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Display name of the deciding rule, empty for the default rule",
+			},
+			"is_default": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether no rule matched and the default rule decided",
+			},
+		},
+	}
+}
+
+// flowConfig decodes the policies, groups and services of d.
+func flowConfig(d *schema.ResourceData) (*dfw.Config, error) {
+	cfg := &dfw.Config{
+		Groups:        map[string]dfw.Group{},
+		Services:      map[string]dfw.Service{},
+		Gateway:       d.Get("gateway_path").(string) != "",
+		DefaultAction: d.Get("default_action").(string),
+	}
+	field, kind := "security_policies", "security policy"
+	if cfg.Gateway {
+		field, kind = "gateway_policies", "gateway policy"
+	}
+	for i, data := range stringList(d.Get(field)) {
+		p, err := dfw.DecodePolicy([]byte(data), fmt.Sprintf("%s #%d", kind, i+1))
+		if err != nil {
+			return nil, err
+		}
+		cfg.Policies = append(cfg.Policies, p)
+	}
+	for path, data := range d.Get("groups").(map[string]interface{}) {
+		g, err := dfw.DecodeGroup([]byte(data.(string)))
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", path, err)
+		}
+		cfg.Groups[path] = g
+	}
+	for path, data := range d.Get("services").(map[string]interface{}) {
+		s, err := dfw.DecodeService([]byte(data.(string)))
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", path, err)
+		}
+		cfg.Services[path] = s
+	}
+	return cfg, nil
+}
+
+// stringList returns the values of a list of strings.
+func stringList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		s, _ := item.(string)
+		values = append(values, s)
+	}
+	return values
+}
@@ -0,0 +1,147 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/exp/slices"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+const (
+	excludeListPath = "/infra/settings/firewall/security/exclude-list"
+
+	// excludeListAttempts bounds the updates of the exclude list retried because another client
+	// changed it in between.
+	excludeListAttempts = 5
+)
+
+// excludeListMu serializes the changes to the exclude list made by this provider instance, the
+// revision of the list catches the others.
+var excludeListMu sync.Mutex
+
+func resourceExcludeListMember(conn *nsxapi.Connection) *schema.Resource {
+	return &schema.Resource{
+		Description: "Adds a member to the distributed firewall exclusion list. Only this member is " +
+			"managed: the members added by other stacks or by hand are kept.",
+		CreateContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			member := d.Get("member").(string)
+			if diags := updateExcludeList(ctx, conn, member, true); diags != nil {
+				return diags
+			}
+			d.SetId(member)
+			return readExcludeListMember(ctx, conn, d)
+		},
+		ReadContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			return readExcludeListMember(ctx, conn, d)
+		},
+		DeleteContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			return updateExcludeList(ctx, conn, d.Id(), false)
+		},
+		Importer: &schema.ResourceImporter{
+			StateContext: func(_ context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+				if _, errs := validatePolicyPath(d.Id(), "id"); len(errs) > 0 {
+					return nil, errs[0]
+				}
+				return []*schema.ResourceData{d}, d.Set("member", d.Id())
+			},
+		},
+		Schema: map[string]*schema.Schema{
+			"member": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+				Description: "Policy path of the group or VM to exclude from the distributed firewall, such as " +
+					"/infra/domains/default/groups/edges",
+				ValidateFunc: validatePolicyPath,
+			},
+		},
+	}
+}
+
+// readExcludeListMember checks that the member is still in the exclude list.
+func readExcludeListMember(ctx context.Context, conn *nsxapi.Connection, d *schema.ResourceData) diag.Diagnostics {
+	c, diags := client(conn)
+	if diags != nil {
+		return diags
+	}
+	var list map[string]interface{}
+	if err := c.Do(ctx, "GET", c.PolicyAPI(excludeListPath), nil, &list); err != nil {
+		return diag.FromErr(err)
+	}
+	if !slices.Contains(stringList(list["members"]), d.Id()) {
+		d.SetId("")
+		return nil
+	}
+	return diag.FromErr(SetAll(d, map[string]interface{}{"member": d.Id()}))
+}
+
+// updateExcludeList adds member to the exclude list, or removes it, leaving the other members
+// alone. Adding a member already in the list fails, as deleting the resource would then remove a
+// member it didn't add. The list is replaced as a whole with its revision, and the update is
+// retried when the list changed in between.
+func updateExcludeList(ctx context.Context, conn *nsxapi.Connection, member string, add bool) diag.Diagnostics {
+	c, diags := client(conn)
+	if diags != nil {
+		return diags
+	}
+	excludeListMu.Lock()
+	defer excludeListMu.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		var list map[string]interface{}
+		if err := c.Do(ctx, "GET", c.PolicyAPI(excludeListPath), nil, &list); err != nil {
+			return diag.FromErr(err)
+		}
+		members := stringList(list["members"])
+		switch found := slices.Contains(members, member); {
+		case found && add:
+			return diag.Errorf("%s is already in the distributed firewall exclusion list, import it to "+
+				"manage it", member)
+		case !found && !add:
+			return nil
+		}
+		if add {
+			members = append(members, member)
+		} else {
+			members = without(members, member)
+		}
+		list["members"] = members
+
+		err := c.Do(ctx, "PUT", c.PolicyAPI(excludeListPath), list, nil)
+		var apiErr *nsxapi.APIError
+		if err == nil || attempt == excludeListAttempts ||
+			!errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusPreconditionFailed {
+			return diag.FromErr(err)
+		}
+	}
+}
+
+func without(values []string, v string) []string {
+	kept := make([]string, 0, len(values))
+	for _, s := range values {
+		if s != v {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,78 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestExcludeListMemberCreate(t *testing.T) {
+	const edges, other = "/infra/domains/default/groups/edges", "/infra/domains/default/groups/other"
+	tests := []struct {
+		name        string
+		members     []string
+		wantErr     string
+		wantMembers []string
+	}{
+		{
+			name:        "added",
+			members:     []string{other},
+			wantMembers: []string{other, edges},
+		},
+		{
+			name:        "already there",
+			members:     []string{edges, other},
+			wantErr:     "already in the distributed firewall exclusion list, import it",
+			wantMembers: []string{edges, other},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			members := tt.members
+			conn := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPut {
+					var list struct{ Members []string }
+					if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+						t.Error(err)
+					}
+					members = list.Members
+				}
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"members": members, "_revision": 1})
+			})
+			res := resourceExcludeListMember(conn)
+			d := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{"member": edges})
+
+			diags := res.CreateContext(context.Background(), d, nil)
+			switch {
+			case tt.wantErr == "" && diags.HasError():
+				t.Fatal(diags)
+			case tt.wantErr != "" && (!diags.HasError() || !strings.Contains(diags[0].Summary, tt.wantErr)):
+				t.Errorf("Create() = %v, want %q", diags, tt.wantErr)
+			case tt.wantErr == "" && d.Id() != edges:
+				t.Errorf("ID = %q, want %q", d.Id(), edges)
+			}
+			if !reflect.DeepEqual(members, tt.wantMembers) {
+				t.Errorf("members = %v, want %v", members, tt.wantMembers)
+			}
+		})
+	}
+}
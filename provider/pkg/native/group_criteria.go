@@ -0,0 +1,137 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/criteria"
+)
+
+// dataSourceGroupCriteria compiles a membership expression, without calling NSX. Its results have
+// the shape of the criteria and conjunction blocks of nsxt_policy_group, to be passed as they are.
+func dataSourceGroupCriteria() *schema.Resource {
+	computedStrings := func(description string) *schema.Schema {
+		return &schema.Schema{
+			Type:        schema.TypeList,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: description,
+		}
+	}
+	staticMembers := func(field, description string) *schema.Schema {
+		return &schema.Schema{
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{field: computedStrings(description)},
+			},
+		}
+	}
+
+	return &schema.Resource{
+		Description: "Compiles a membership expression, such as `vm.tag[app] == \"web\" || ip in 10.0.0.0/24`, " +
+			"into the criteria and conjunctions of a group.",
+		ReadContext: func(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			expression := d.Get("expression").(string)
+			compiled, err := criteria.Compile(expression)
+			if err != nil {
+				return diag.Errorf("invalid group expression: %v", err)
+			}
+
+			blocks := make([]interface{}, 0, len(compiled.Criteria))
+			for _, c := range compiled.Criteria {
+				conditions := make([]interface{}, 0, len(c.Conditions))
+				for _, cond := range c.Conditions {
+					conditions = append(conditions, map[string]interface{}{
+						"member_type": cond.MemberType,
+						"key":         cond.Key,
+						"operator":    cond.Operator,
+						"value":       cond.Value,
+					})
+				}
+				block := map[string]interface{}{"condition": conditions}
+				if len(c.IPAddresses) > 0 {
+					block["ipaddress_expression"] = []interface{}{map[string]interface{}{"ip_addresses": c.IPAddresses}}
+				}
+				if len(c.MACAddresses) > 0 {
+					block["macaddress_expression"] = []interface{}{map[string]interface{}{"mac_addresses": c.MACAddresses}}
+				}
+				if len(c.MemberPaths) > 0 {
+					block["path_expression"] = []interface{}{map[string]interface{}{"member_paths": c.MemberPaths}}
+				}
+				blocks = append(blocks, block)
+			}
+			conjunctions := make([]interface{}, 0, len(compiled.Conjunctions))
+			for _, operator := range compiled.Conjunctions {
+				conjunctions = append(conjunctions, map[string]interface{}{"operator": operator})
+			}
+
+			d.SetId(expression)
+			return diag.FromErr(SetAll(d, map[string]interface{}{
+				"criteria":    blocks,
+				"conjunction": conjunctions,
+			}))
+		},
+		Schema: map[string]*schema.Schema{
+			"expression": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Membership expression",
+			},
+			"criteria": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Criteria of the group",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"condition": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"member_type": {Type: schema.TypeString, Computed: true},
+									"key":         {Type: schema.TypeString, Computed: true},
+									"operator":    {Type: schema.TypeString, Computed: true},
+									"value":       {Type: schema.TypeString, Computed: true},
+								},
+							},
+						},
+						"ipaddress_expression":  staticMembers("ip_addresses", "IP addresses, networks and ranges"),
+						"macaddress_expression": staticMembers("mac_addresses", "MAC addresses"),
+						"path_expression":       staticMembers("member_paths", "Policy paths of the members"),
+					},
+				},
+			},
+			"conjunction": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Conjunctions between the criteria",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"operator": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "AND or OR",
+						},
+					},
+				},
+			},
+		},
+	}
+}
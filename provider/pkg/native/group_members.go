@@ -0,0 +1,177 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+// memberKind is a type of effective member of a group.
+type memberKind struct {
+	endpoint    string // effective membership endpoint, under the path of the group
+	description string
+	plain       bool // whether the members are plain strings rather than objects
+}
+
+// memberKinds are the types of effective members, by field name.
+var memberKinds = map[string]memberKind{
+	"virtual_machines": {endpoint: "virtual-machines", description: "Virtual machines"},
+	"ip_addresses":     {endpoint: "ip-addresses", description: "IP addresses, ranges and networks", plain: true},
+	"segments":         {endpoint: "segments", description: "Segments"},
+	"segment_ports":    {endpoint: "segment-ports", description: "Segment ports"},
+	"vifs":             {endpoint: "vifs", description: "Virtual network interfaces"},
+	"physical_servers": {endpoint: "physical-servers", description: "Physical servers"},
+}
+
+func dataSourceGroupEffectiveMembers(conn *nsxapi.Connection) *schema.Resource {
+	s := map[string]*schema.Schema{
+		"path": {
+			Type:         schema.TypeString,
+			Required:     true,
+			Description:  "Policy path of the group, such as /infra/domains/default/groups/web",
+			ValidateFunc: validatePolicyPath,
+		},
+		"enforcement_point_path": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Policy path of the enforcement point to get the members from, the default one when not set",
+		},
+		"member_count": {
+			Type:        schema.TypeInt,
+			Computed:    true,
+			Description: "Total number of effective members, of all types",
+		},
+	}
+	for name, kind := range memberKinds {
+		if kind.plain {
+			s[name] = &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: kind.description + " of the group",
+			}
+			continue
+		}
+		s[name] = &schema.Schema{
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: kind.description + " of the group",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"id": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "ID of the member: external ID for VMs and interfaces, else NSX ID",
+					},
+					"display_name": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "Display name of the member",
+					},
+					"path": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "Policy path of the member, for segments and ports",
+					},
+				},
+			},
+		}
+	}
+
+	return &schema.Resource{
+		Description: "Lists the effective members of a group, the objects its criteria and static members " +
+			"resolve to, by type. The member types the manager doesn't support are left empty.",
+		ReadContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			c, diags := client(conn)
+			if diags != nil {
+				return diags
+			}
+			path := d.Get("path").(string)
+			query := ""
+			if ep := d.Get("enforcement_point_path").(string); ep != "" {
+				query = "?enforcement_point_path=" + url.QueryEscape(ep)
+			}
+
+			values := map[string]interface{}{}
+			count, unsupported := 0, 0
+			for name, kind := range memberKinds {
+				raws, err := c.ListRaw(ctx, c.PolicyAPI(path+"/members/"+kind.endpoint+query))
+				if nsxapi.IsNotFound(err) {
+					// Not a member type of this NSX version, such as physical servers.
+					values[name] = []interface{}{}
+					unsupported++
+					continue
+				}
+				if err != nil {
+					return diag.FromErr(fmt.Errorf("cannot list the %s of %s: %w", kind.endpoint, path, err))
+				}
+				members, err := decodeMembers(raws, kind.plain)
+				if err != nil {
+					return diag.FromErr(fmt.Errorf("cannot decode the %s of %s: %w", kind.endpoint, path, err))
+				}
+				values[name] = members
+				count += len(members)
+			}
+			if unsupported == len(memberKinds) {
+				return diag.Errorf("group %s not found", path)
+			}
+			values["member_count"] = count
+			d.SetId(path)
+			return diag.FromErr(SetAll(d, values))
+		},
+		Schema: s,
+	}
+}
+
+// decodeMembers returns the members of a type, as strings or in the shape of the member schema.
+func decodeMembers(raws []json.RawMessage, plain bool) ([]interface{}, error) {
+	members := make([]interface{}, 0, len(raws))
+	for _, raw := range raws {
+		if plain {
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, err
+			}
+			members = append(members, s)
+			continue
+		}
+		var obj map[string]interface{}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, err
+		}
+		id := stringField(obj, "external_id")
+		if id == "" {
+			id = stringField(obj, "id")
+		}
+		name := stringField(obj, "display_name")
+		if name == "" {
+			name = stringField(obj, "target_display_name")
+		}
+		members = append(members, map[string]interface{}{
+			"id":           id,
+			"display_name": name,
+			"path":         stringField(obj, "path"),
+		})
+	}
+	return members, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestGroupEffectiveMembers(t *testing.T) {
+	const group = "/policy/api/v1/infra/domains/default/groups/web/members/"
+	tests := []struct {
+		name    string
+		served  map[string]string
+		wantErr string
+		want    map[string]string
+	}{
+		{
+			name: "unsupported member types skipped",
+			served: map[string]string{
+				"virtual-machines": `{"results":[{"external_id":"vm-1","display_name":"web-1"}],"result_count":1}`,
+				"ip-addresses":     `{"results":["10.0.0.1"],"result_count":1}`,
+				"segments":         `{"results":[],"result_count":0}`,
+				"segment-ports":    `{"results":[],"result_count":0}`,
+				"vifs":             `{"results":[],"result_count":0}`,
+			},
+			want: map[string]string{
+				"member_count":          "2",
+				"virtual_machines.#":    "1",
+				"virtual_machines.0.id": "vm-1",
+				"ip_addresses.0":        "10.0.0.1",
+				"physical_servers.#":    "0",
+			},
+		},
+		{
+			name:    "missing group",
+			served:  map[string]string{},
+			wantErr: "not found",
+		},
+		{
+			name: "server error",
+			served: map[string]string{
+				"virtual-machines": "500",
+			},
+			wantErr: "virtual-machines",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+				body, ok := tt.served[strings.TrimPrefix(r.URL.Path, group)]
+				switch {
+				case !ok:
+					w.WriteHeader(http.StatusNotFound)
+				case body == "500":
+					w.WriteHeader(http.StatusInternalServerError)
+				default:
+					_, _ = io.WriteString(w, body)
+				}
+			})
+			res := dataSourceGroupEffectiveMembers(conn)
+			d := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{
+				"path": "/infra/domains/default/groups/web",
+			})
+			diags := res.ReadContext(context.Background(), d, nil)
+			if tt.wantErr != "" {
+				if !diags.HasError() || !strings.Contains(diags[0].Summary, tt.wantErr) {
+					t.Fatalf("got %v, want an error about %q", diags, tt.wantErr)
+				}
+				return
+			}
+			if diags.HasError() {
+				t.Fatal(diags)
+			}
+			state := d.State()
+			for k, want := range tt.want {
+				if got := state.Attributes[k]; got != want {
+					t.Errorf("%s = %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
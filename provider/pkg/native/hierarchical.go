@@ -0,0 +1,54 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+// patchDomain applies changes to the objects of a domain in a single call of the hierarchical
+// Policy API, which NSX applies as a whole or not at all. children are built with child.
+func patchDomain(ctx context.Context, c *nsxapi.Client, domain string, children []interface{}) error {
+	if len(children) == 0 {
+		return nil
+	}
+	infra := map[string]interface{}{
+		"resource_type": "Infra",
+		"children": []interface{}{
+			map[string]interface{}{
+				"resource_type": "ChildDomain",
+				"Domain": map[string]interface{}{
+					"resource_type": "Domain",
+					"id":            domain,
+					"children":      children,
+				},
+			},
+		},
+	}
+	return c.Do(ctx, "PATCH", c.PolicyAPI("/infra"), infra, nil)
+}
+
+// child wraps obj, of the given resource type (Group, SecurityPolicy, Rule), as a child in a
+// hierarchical call, to be deleted when del is set.
+func child(resourceType string, obj map[string]interface{}, del bool) map[string]interface{} {
+	obj["resource_type"] = resourceType
+	return map[string]interface{}{
+		"resource_type":     "Child" + resourceType,
+		resourceType:        obj,
+		"marked_for_delete": del,
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// serverManaged are the fields NSX sets on Policy objects, besides the ones starting with '_'
+// (_revision, _create_time, ...). They are never sent nor compared.
+var serverManaged = map[string]bool{
+	"id":                true,
+	"path":              true,
+	"parent_path":       true,
+	"relative_path":     true,
+	"unique_id":         true,
+	"realization_id":    true,
+	"marked_for_delete": true,
+	"overridden":        true,
+	"origin_site_id":    true,
+	"owner_id":          true,
+	"remote_path":       true,
+}
+
+func isServerManaged(key string) bool {
+	return strings.HasPrefix(key, "_") || serverManaged[key]
+}
+
+// parseObject parses a JSON object.
+func parseObject(s string) (map[string]interface{}, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &obj); err != nil {
+		return nil, fmt.Errorf("invalid JSON object: %w", err)
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("invalid JSON object: null")
+	}
+	return obj, nil
+}
+
+// withoutServerManaged returns obj without its server-managed top-level fields.
+func withoutServerManaged(obj map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		if !isServerManaged(k) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// project returns the values of actual for the fields present in specified, recursively through
+// objects, so that fields the server adds don't show up as differences. Server-managed fields keep
+// their specified value. Arrays are taken as a whole, except arrays of objects of the same length
+// which are projected element by element.
+func project(specified, actual interface{}) interface{} {
+	switch spec := specified.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			return actual
+		}
+		out := make(map[string]interface{}, len(spec))
+		for k, v := range spec {
+			if isServerManaged(k) {
+				out[k] = v
+				continue
+			}
+			if a, ok := act[k]; ok {
+				out[k] = project(v, a)
+			} else {
+				out[k] = nil
+			}
+		}
+		return out
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok || len(act) != len(spec) {
+			return actual
+		}
+		out := make([]interface{}, len(spec))
+		for i := range spec {
+			out[i] = project(spec[i], act[i])
+		}
+		return out
+	}
+	return actual
+}
+
+// jsonEqual tells whether two JSON documents hold the same value. Invalid documents are only
+// equal to themselves.
+func jsonEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// marshal encodes v as JSON, with sorted keys.
+func marshal(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
@@ -0,0 +1,122 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestProject(t *testing.T) {
+	tests := []struct {
+		name      string
+		specified string
+		actual    string
+		want      string
+	}{
+		{
+			name:      "fields added by the server",
+			specified: `{"display_name": "web"}`,
+			actual:    `{"display_name": "web", "path": "/infra/web", "_create_time": 1}`,
+			want:      `{"display_name": "web"}`,
+		},
+		{
+			name:      "field changed out of band",
+			specified: `{"display_name": "web"}`,
+			actual:    `{"display_name": "front"}`,
+			want:      `{"display_name": "front"}`,
+		},
+		{
+			name:      "field removed out of band",
+			specified: `{"display_name": "web", "description": "x"}`,
+			actual:    `{"display_name": "web"}`,
+			want:      `{"display_name": "web", "description": null}`,
+		},
+		{
+			name:      "server-managed field keeps its specified value",
+			specified: `{"display_name": "web", "_revision": 3}`,
+			actual:    `{"display_name": "web", "_revision": 7}`,
+			want:      `{"display_name": "web", "_revision": 3}`,
+		},
+		{
+			name:      "nested objects",
+			specified: `{"spec": {"size": 2}}`,
+			actual:    `{"spec": {"size": 2, "unit": "GB"}}`,
+			want:      `{"spec": {"size": 2}}`,
+		},
+		{
+			name:      "object replaced by another type",
+			specified: `{"spec": {"size": 2}}`,
+			actual:    `{"spec": "none"}`,
+			want:      `{"spec": "none"}`,
+		},
+		{
+			name:      "arrays of objects of the same length",
+			specified: `{"rules": [{"name": "a"}, {"name": "b"}]}`,
+			actual:    `{"rules": [{"name": "a", "id": 1}, {"name": "c", "id": 2}]}`,
+			want:      `{"rules": [{"name": "a"}, {"name": "c"}]}`,
+		},
+		{
+			name:      "arrays of different lengths",
+			specified: `{"rules": [{"name": "a"}]}`,
+			actual:    `{"rules": [{"name": "a", "id": 1}, {"name": "b", "id": 2}]}`,
+			want:      `{"rules": [{"name": "a", "id": 1}, {"name": "b", "id": 2}]}`,
+		},
+		{
+			name:      "arrays of scalars",
+			specified: `{"members": ["a", "b"]}`,
+			actual:    `{"members": ["b", "a"]}`,
+			want:      `{"members": ["b", "a"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			specified, actual, want := decodeJSON(t, tt.specified), decodeJSON(t, tt.actual), decodeJSON(t, tt.want)
+			if got := project(specified, actual); !reflect.DeepEqual(got, want) {
+				t.Errorf("project() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestJSONEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{`{"a": 1, "b": [1, 2]}`, `{"b":[1,2],"a":1}`, true},
+		{`{"a": 1}`, `{"a": 1.0}`, true},
+		{`{"a": 1}`, `{"a": 2}`, false},
+		{`{"a": [1, 2]}`, `{"a": [2, 1]}`, false},
+		{`{"a": null}`, `{}`, false},
+		{`{"a": `, `{"a": `, true},
+		{`{"a": `, `{"a": 1}`, false},
+	}
+	for _, tt := range tests {
+		if got := jsonEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("jsonEqual(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func decodeJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
@@ -0,0 +1,165 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+// listFamily is a kind of Policy object listed by a plural data source.
+type listFamily struct {
+	name       string // plural name of the objects in descriptions
+	collection string // Policy path of the objects, %s being the domain for domain objects
+	domain     bool   // whether the objects belong to a domain
+	projects   bool   // whether the objects can belong to a project
+}
+
+// listFamilies are the plural data sources, by Terraform name.
+var listFamilies = map[string]listFamily{
+	"nsxt_policy_segments":       {name: "segments", collection: "/infra/segments", projects: true},
+	"nsxt_policy_groups":         {name: "groups", collection: "/infra/domains/%s/groups", domain: true, projects: true},
+	"nsxt_policy_services":       {name: "services", collection: "/infra/services", projects: true},
+	"nsxt_policy_tier0_gateways": {name: "Tier-0 gateways", collection: "/infra/tier-0s"},
+	"nsxt_policy_tier1_gateways": {name: "Tier-1 gateways", collection: "/infra/tier-1s", projects: true},
+	"nsxt_policy_ip_blocks":      {name: "IP blocks", collection: "/infra/ip-blocks", projects: true},
+	"nsxt_policy_ip_pools":       {name: "IP pools", collection: "/infra/ip-pools", projects: true},
+	"nsxt_policy_lb_services":    {name: "load balancer services", collection: "/infra/lb-services"},
+	"nsxt_policy_projects":       {name: "projects", collection: "/orgs/default/projects"},
+}
+
+func dataSourceList(conn *nsxapi.Connection, family listFamily) *schema.Resource {
+	s := map[string]*schema.Schema{
+		"tag": tagFilterSchema(),
+		"display_name": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Description:  "Regular expression the display names of the " + family.name + " must match",
+			ValidateFunc: validation.StringIsValidRegExp,
+		},
+		"items": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Display names of the " + family.name + ", by ID",
+		},
+		"paths": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Policy paths of the " + family.name + ", by ID",
+		},
+		"objects": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "JSON of the " + family.name + " as returned by NSX, by ID",
+		},
+	}
+	if family.domain {
+		s["domain"] = &schema.Schema{
+			Type:        schema.TypeString,
+			Optional:    true,
+			Default:     "default",
+			Description: "Domain of the " + family.name,
+		}
+	}
+	if family.projects {
+		s["context"] = &schema.Schema{
+			Type:        schema.TypeList,
+			Optional:    true,
+			MaxItems:    1,
+			Description: "Project of the " + family.name + ", the default space when not set",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"project_id": {
+						Type:         schema.TypeString,
+						Required:     true,
+						Description:  "ID of the project",
+						ValidateFunc: validation.StringIsNotWhiteSpace,
+					},
+				},
+			},
+		}
+	}
+
+	return &schema.Resource{
+		Description: "Lists the " + family.name + ", optionally filtered by tags and display name.",
+		ReadContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			c, diags := client(conn)
+			if diags != nil {
+				return diags
+			}
+			collection := family.collection
+			if family.domain {
+				collection = fmt.Sprintf(collection, d.Get("domain").(string))
+			}
+			if project := projectOf(d); project != "" {
+				collection = "/orgs/default/projects/" + project + collection
+			}
+			var displayName *regexp.Regexp
+			if expr := d.Get("display_name").(string); expr != "" {
+				displayName = regexp.MustCompile(expr)
+			}
+			tags := expandTags(d.Get("tag").([]interface{}))
+
+			objects, err := c.List(ctx, c.PolicyAPI(collection))
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			items := map[string]interface{}{}
+			paths := map[string]interface{}{}
+			raws := map[string]interface{}{}
+			for _, obj := range objects {
+				name := stringField(obj, "display_name")
+				if !hasTags(obj, tags) || displayName != nil && !displayName.MatchString(name) {
+					continue
+				}
+				raw, err := marshal(obj)
+				if err != nil {
+					return diag.FromErr(err)
+				}
+				id := stringField(obj, "id")
+				items[id] = name
+				paths[id] = stringField(obj, "path")
+				raws[id] = raw
+			}
+			d.SetId(collection)
+			return diag.FromErr(SetAll(d, map[string]interface{}{
+				"items":   items,
+				"paths":   paths,
+				"objects": raws,
+			}))
+		},
+		Schema: s,
+	}
+}
+
+// projectOf returns the project ID of the context of d, or "".
+func projectOf(d *schema.ResourceData) string {
+	contexts, _ := d.Get("context").([]interface{})
+	if len(contexts) == 0 || contexts[0] == nil {
+		return ""
+	}
+	project, _ := contexts[0].(map[string]interface{})["project_id"].(string)
+	return project
+}
@@ -0,0 +1,65 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package native holds the resources and data sources implemented by this provider rather than by
+// the upstream Terraform provider. They are Terraform resources too, added to the upstream provider,
+// so that the bridge handles them like the others, but they call NSX with the client of the
+// connection.
+package native
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+// Resources returns the native resources, by Terraform name.
+func Resources(conn *nsxapi.Connection) map[string]*schema.Resource {
+	return map[string]*schema.Resource{
+		"nsxt_policy_object":                  resourcePolicyObject(conn),
+		"nsxt_policy_sharded_group":           resourceShardedGroup(conn),
+		"nsxt_policy_sharded_security_policy": resourceShardedSecurityPolicy(conn),
+		"nsxt_policy_security_policy_rule":    resourcePolicyRule(conn, false),
+		"nsxt_policy_gateway_policy_rule":     resourcePolicyRule(conn, true),
+
+		"nsxt_policy_firewall_exclude_list_member": resourceExcludeListMember(conn),
+		"nsxt_policy_bulk_vm_tags":                 resourceBulkVMTags(conn),
+	}
+}
+
+// DataSources returns the native data sources, by Terraform name.
+func DataSources(conn *nsxapi.Connection) map[string]*schema.Resource {
+	dataSources := map[string]*schema.Resource{
+		"nsxt_nsx_capabilities": dataSourceCapabilities(conn),
+		"nsxt_search":           dataSourceSearch(conn),
+
+		"nsxt_policy_group_effective_members": dataSourceGroupEffectiveMembers(conn),
+		"nsxt_policy_group_criteria":          dataSourceGroupCriteria(),
+		"nsxt_evaluate_flow":                  dataSourceEvaluateFlow(),
+	}
+	for name, family := range listFamilies {
+		dataSources[name] = dataSourceList(conn, family)
+	}
+	return dataSources
+}
+
+// client returns the client of conn, or an error when the provider isn't connected.
+func client(conn *nsxapi.Connection) (*nsxapi.Client, diag.Diagnostics) {
+	c := conn.Client()
+	if c == nil {
+		return nil, diag.Errorf("the provider is not connected to an NSX manager")
+	}
+	return c, nil
+}
@@ -0,0 +1,230 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+// defaultRealizationTimeout bounds the wait for realization when no custom timeout is set.
+const defaultRealizationTimeout = 10 * time.Minute
+
+func resourcePolicyObject(conn *nsxapi.Connection) *schema.Resource {
+	return &schema.Resource{
+		Description: "Manages any Policy API object given its path and JSON body, for the features the " +
+			"other resources don't cover. Only the fields of `body` are managed: the fields NSX adds " +
+			"or computes are ignored. Creating an object that already exists fails: import it instead.",
+		CreateContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			path := d.Get("path").(string)
+			if diags := requireAbsent(ctx, conn, path); diags != nil {
+				return diags
+			}
+			if diags := patchPolicyObject(ctx, conn, d, schema.TimeoutCreate); diags != nil {
+				return diags
+			}
+			d.SetId(path)
+			return readPolicyObject(ctx, conn, d)
+		},
+		ReadContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			return readPolicyObject(ctx, conn, d)
+		},
+		UpdateContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			if diags := patchPolicyObject(ctx, conn, d, schema.TimeoutUpdate); diags != nil {
+				return diags
+			}
+			return readPolicyObject(ctx, conn, d)
+		},
+		DeleteContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			c, diags := client(conn)
+			if diags != nil {
+				return diags
+			}
+			if err := c.Do(ctx, "DELETE", c.PolicyAPI(d.Id()), nil, nil); err != nil && !nsxapi.IsNotFound(err) {
+				return diag.FromErr(err)
+			}
+			return nil
+		},
+		Importer: &schema.ResourceImporter{
+			StateContext: importPolicyObject(conn),
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(defaultRealizationTimeout),
+			Update: schema.DefaultTimeout(defaultRealizationTimeout),
+		},
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				Description:  "Policy path of the object, such as /infra/domains/default/groups/web",
+				ValidateFunc: validatePolicyPath,
+			},
+			"body": {
+				Type:     schema.TypeString,
+				Required: true,
+				Description: "JSON body of the object. Server-managed fields (`_revision`, `_create_time`, " +
+					"`path`, ...) are ignored",
+				ValidateFunc: func(v interface{}, k string) ([]string, []error) {
+					if _, err := parseObject(v.(string)); err != nil {
+						return nil, []error{fmt.Errorf("%s: %w", k, err)}
+					}
+					return nil, nil
+				},
+				DiffSuppressFunc: func(_, old, new string, _ *schema.ResourceData) bool {
+					return jsonEqual(old, new)
+				},
+			},
+			"wait_for_realization": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+				Description: "Wait for the object to be realized on the enforcement points after each " +
+					"change, within the create or update timeout",
+			},
+			"revision": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Revision of the object in NSX",
+			},
+			"result": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "JSON of the whole object as returned by NSX",
+			},
+		},
+	}
+}
+
+// validatePolicyPath checks that a path is a Policy path, relative to the Policy API.
+func validatePolicyPath(v interface{}, k string) ([]string, []error) {
+	path := v.(string)
+	if !strings.HasPrefix(path, "/") || strings.HasSuffix(path, "/") || strings.Contains(path, "/api/") {
+		return nil, []error{fmt.Errorf("%s: %q is not a policy path such as /infra/domains/default", k, path)}
+	}
+	return nil, nil
+}
+
+// requireAbsent fails when the object at path exists already: PATCH would merge the body into it
+// and the stack would then delete an object it didn't create.
+func requireAbsent(ctx context.Context, conn *nsxapi.Connection, path string) diag.Diagnostics {
+	c, diags := client(conn)
+	if diags != nil {
+		return diags
+	}
+	err := c.Do(ctx, "GET", c.PolicyAPI(path), nil, nil)
+	switch {
+	case err == nil:
+		return diag.Errorf("%s already exists, import it to manage it", path)
+	case nsxapi.IsNotFound(err):
+		return nil
+	}
+	return diag.FromErr(err)
+}
+
+func patchPolicyObject(ctx context.Context, conn *nsxapi.Connection, d *schema.ResourceData,
+	timeout string) diag.Diagnostics {
+	c, diags := client(conn)
+	if diags != nil {
+		return diags
+	}
+	path := d.Get("path").(string)
+	body, err := parseObject(d.Get("body").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := c.Do(ctx, "PATCH", c.PolicyAPI(path), withoutServerManaged(body), nil); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if d.Get("wait_for_realization").(bool) {
+		ctx, cancel := context.WithTimeout(ctx, d.Timeout(timeout))
+		defer cancel()
+		if err := c.WaitForRealization(ctx, path); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	return nil
+}
+
+// readPolicyObject reads the object back. body keeps the fields of the configured body only, with
+// the values NSX has, so that changes made out of band to these fields show up as differences.
+func readPolicyObject(ctx context.Context, conn *nsxapi.Connection, d *schema.ResourceData) diag.Diagnostics {
+	c, diags := client(conn)
+	if diags != nil {
+		return diags
+	}
+	var actual map[string]interface{}
+	if err := c.Do(ctx, "GET", c.PolicyAPI(d.Id()), nil, &actual); err != nil {
+		if nsxapi.IsNotFound(err) {
+			d.SetId("")
+			return nil
+		}
+		return diag.FromErr(err)
+	}
+
+	specified, err := parseObject(d.Get("body").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	body, err := marshal(project(specified, actual))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	result, err := marshal(actual)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	revision, _ := actual["_revision"].(float64)
+	return diag.FromErr(SetAll(d, map[string]interface{}{
+		"path":     d.Id(),
+		"body":     body,
+		"revision": int(revision),
+		"result":   result,
+	}))
+}
+
+// importPolicyObject imports the object at the path given as ID, with all its fields but the
+// server-managed ones as body.
+func importPolicyObject(conn *nsxapi.Connection) schema.StateContextFunc {
+	return func(ctx context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+		if _, errs := validatePolicyPath(d.Id(), "id"); len(errs) > 0 {
+			return nil, errs[0]
+		}
+		c := conn.Client()
+		if c == nil {
+			return nil, fmt.Errorf("the provider is not connected to an NSX manager")
+		}
+		var actual map[string]interface{}
+		if err := c.Do(ctx, "GET", c.PolicyAPI(d.Id()), nil, &actual); err != nil {
+			return nil, err
+		}
+		body, err := marshal(withoutServerManaged(actual))
+		if err != nil {
+			return nil, err
+		}
+		if err := d.Set("body", body); err != nil {
+			return nil, err
+		}
+		return []*schema.ResourceData{d}, nil
+	}
+}
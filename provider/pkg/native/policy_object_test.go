@@ -0,0 +1,72 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestPolicyObjectCreate(t *testing.T) {
+	const path = "/infra/domains/default/groups/web"
+	tests := []struct {
+		name    string
+		exists  bool
+		wantErr string
+		patched bool
+	}{
+		{name: "created", patched: true},
+		{name: "already there", exists: true, wantErr: path + " already exists, import it"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exists, patched := tt.exists, false
+			conn := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodPatch:
+					exists, patched = true, true
+				case http.MethodGet:
+					if !exists {
+						http.Error(w, `{"error_code": 500090}`, http.StatusNotFound)
+						return
+					}
+					_, _ = w.Write([]byte(`{"display_name": "web", "_revision": 0}`))
+				}
+			})
+			res := resourcePolicyObject(conn)
+			d := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{
+				"path": path,
+				"body": `{"display_name": "web"}`,
+			})
+
+			diags := res.CreateContext(context.Background(), d, nil)
+			switch {
+			case tt.wantErr == "" && diags.HasError():
+				t.Fatal(diags)
+			case tt.wantErr != "" && (!diags.HasError() || !strings.Contains(diags[0].Summary, tt.wantErr)):
+				t.Errorf("Create() = %v, want %q", diags, tt.wantErr)
+			case tt.wantErr == "" && d.Id() != path:
+				t.Errorf("ID = %q, want %q", d.Id(), path)
+			}
+			if patched != tt.patched {
+				t.Errorf("patched = %v, want %v", patched, tt.patched)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+// resourcePolicyRule manages a single rule of a security policy, or of a gateway policy when
+// gateway is set, so that teams can own their rules in a policy they share.
+func resourcePolicyRule(conn *nsxapi.Connection, gateway bool) *schema.Resource {
+	kind, collection := "security policy", "/security-policies/"
+	if gateway {
+		kind, collection = "gateway policy", "/gateway-policies/"
+	}
+
+	s := ruleSchema().Schema
+	s["nsx_id"] = &schema.Schema{
+		Type:         schema.TypeString,
+		Required:     true,
+		ForceNew:     true,
+		Description:  "NSX ID of the rule",
+		ValidateFunc: validation.StringIsNotWhiteSpace,
+	}
+	s["policy_path"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Required:    true,
+		ForceNew:    true,
+		Description: fmt.Sprintf("Policy path of the %s holding the rule", kind),
+		ValidateFunc: func(v interface{}, k string) ([]string, []error) {
+			if !strings.Contains(v.(string), collection) {
+				return nil, []error{fmt.Errorf("%s: %q is not the path of a %s", k, v, kind)}
+			}
+			return validatePolicyPath(v, k)
+		},
+	}
+	s["sequence_number"] = &schema.Schema{
+		Type:     schema.TypeInt,
+		Required: true,
+		Description: "Sequence number of the rule within the policy. It must not collide with those " +
+			"of the other rules of the policy",
+	}
+	s["path"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Computed:    true,
+		Description: "Policy path of the rule",
+	}
+	s["revision"] = &schema.Schema{
+		Type:        schema.TypeInt,
+		Computed:    true,
+		Description: "Revision of the rule in NSX",
+	}
+	if gateway {
+		// Gateway rules apply to gateways, NSX rejects ANY.
+		s["scope"].Optional, s["scope"].Required = false, true
+		s["scope"].Description = "Paths of the gateways and interfaces the rule applies to"
+	}
+
+	return &schema.Resource{
+		Description: fmt.Sprintf("Manages a single rule of an existing %s, with its own sequence number. "+
+			"Set ignoreUnmanagedRules on the policy resource so that it leaves the rule alone.", kind),
+		CreateContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			if diags := patchPolicyRule(ctx, conn, d); diags != nil {
+				return diags
+			}
+			d.SetId(rulePath(d.Get("policy_path").(string), d.Get("nsx_id").(string)))
+			return readPolicyRule(ctx, conn, d)
+		},
+		ReadContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			return readPolicyRule(ctx, conn, d)
+		},
+		UpdateContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			if diags := patchPolicyRule(ctx, conn, d); diags != nil {
+				return diags
+			}
+			return readPolicyRule(ctx, conn, d)
+		},
+		DeleteContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			c, diags := client(conn)
+			if diags != nil {
+				return diags
+			}
+			if err := c.Do(ctx, "DELETE", c.PolicyAPI(d.Id()), nil, nil); err != nil && !nsxapi.IsNotFound(err) {
+				return diag.FromErr(err)
+			}
+			return nil
+		},
+		Importer: &schema.ResourceImporter{
+			StateContext: func(_ context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+				policyPath, id, ok := strings.Cut(d.Id(), "/rules/")
+				if !ok || !strings.Contains(policyPath, collection) || id == "" || strings.Contains(id, "/") {
+					return nil, fmt.Errorf("%q is not the path of a %s rule", d.Id(), kind)
+				}
+				if err := d.Set("policy_path", policyPath); err != nil {
+					return nil, err
+				}
+				if err := d.Set("nsx_id", id); err != nil {
+					return nil, err
+				}
+				return []*schema.ResourceData{d}, nil
+			},
+		},
+		Schema: s,
+	}
+}
+
+func rulePath(policyPath, id string) string {
+	return policyPath + "/rules/" + id
+}
+
+func patchPolicyRule(ctx context.Context, conn *nsxapi.Connection, d *schema.ResourceData) diag.Diagnostics {
+	c, diags := client(conn)
+	if diags != nil {
+		return diags
+	}
+	rule := map[string]interface{}{}
+	for k := range ruleSchema().Schema {
+		rule[k] = d.Get(k)
+	}
+	path := rulePath(d.Get("policy_path").(string), d.Get("nsx_id").(string))
+	payload := rulePayload(rule, d.Get("sequence_number").(int))
+	return diag.FromErr(c.Do(ctx, "PATCH", c.PolicyAPI(path), payload, nil))
+}
+
+// readPolicyRule reads the rule back, so that changes made out of band show up as differences.
+func readPolicyRule(ctx context.Context, conn *nsxapi.Connection, d *schema.ResourceData) diag.Diagnostics {
+	c, diags := client(conn)
+	if diags != nil {
+		return diags
+	}
+	var rule map[string]interface{}
+	if err := c.Do(ctx, "GET", c.PolicyAPI(d.Id()), nil, &rule); err != nil {
+		if nsxapi.IsNotFound(err) {
+			d.SetId("")
+			return nil
+		}
+		return diag.FromErr(err)
+	}
+	paths := func(key string) []string {
+		values := stringList(rule[key])
+		if len(values) == 1 && values[0] == "ANY" {
+			return nil
+		}
+		return values
+	}
+	flag := func(key string) bool {
+		v, _ := rule[key].(bool)
+		return v
+	}
+	sequenceNumber, _ := rule["sequence_number"].(float64)
+	revision, _ := rule["_revision"].(float64)
+	return diag.FromErr(SetAll(d, map[string]interface{}{
+		"display_name":          stringField(rule, "display_name"),
+		"description":           stringField(rule, "description"),
+		"notes":                 stringField(rule, "notes"),
+		"action":                stringField(rule, "action"),
+		"source_groups":         paths("source_groups"),
+		"destination_groups":    paths("destination_groups"),
+		"services":              paths("services"),
+		"scope":                 paths("scope"),
+		"profiles":              paths("profiles"),
+		"direction":             stringField(rule, "direction"),
+		"ip_version":            stringField(rule, "ip_protocol"),
+		"logged":                flag("logged"),
+		"log_label":             stringField(rule, "tag"),
+		"disabled":              flag("disabled"),
+		"sources_excluded":      flag("sources_excluded"),
+		"destinations_excluded": flag("destinations_excluded"),
+		"tag":                   flattenTags(tagsOf(rule)),
+		"sequence_number":       int(sequenceNumber),
+		"path":                  d.Id(),
+		"revision":              int(revision),
+	}))
+}
+
+// IgnoreUnmanagedRules adds ignore_unmanaged_rules to a policy resource of the upstream provider
+// (nsxt_policy_security_policy, nsxt_policy_gateway_policy). When set, the rules of the policy
+// that the resource doesn't configure are left out of its state, so that it neither reports nor
+// deletes the rules managed by nsxt_policy_security_policy_rule and the like. Rules are told
+// apart by nsx_id, or display name for the configured rules without one: an unmanaged rule with
+// the display name of such a rule is taken for it, and reported and deleted with the policy.
+func IgnoreUnmanagedRules(res *schema.Resource) {
+	if res == nil {
+		return
+	}
+	res.Schema["ignore_unmanaged_rules"] = &schema.Schema{
+		Type:     schema.TypeBool,
+		Optional: true,
+		Default:  false,
+		Description: "Leave the rules of the policy this resource doesn't configure alone, such as the " +
+			"rules managed by standalone rule resources. Rules without nsxId are told apart by display name, " +
+			"which standalone rules must then not reuse",
+	}
+
+	// The upstream create and update read the policy back without going through Read, they are
+	// wrapped too.
+	withContext := func(f func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(
+		context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+		return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+			keep := managedRules(d)
+			if diags := f(ctx, d, meta); diags.HasError() {
+				return diags
+			}
+			return diag.FromErr(keep())
+		}
+	}
+	without := func(f func(*schema.ResourceData, interface{}) error) func(*schema.ResourceData, interface{}) error {
+		return func(d *schema.ResourceData, meta interface{}) error {
+			keep := managedRules(d)
+			if err := f(d, meta); err != nil {
+				return err
+			}
+			return keep()
+		}
+	}
+	if res.CreateContext != nil {
+		res.CreateContext = withContext(res.CreateContext)
+	}
+	if res.ReadContext != nil {
+		res.ReadContext = withContext(res.ReadContext)
+	}
+	if res.UpdateContext != nil {
+		res.UpdateContext = withContext(res.UpdateContext)
+	}
+	// The upstream provider still uses the deprecated functions.
+	//nolint:staticcheck
+	if res.Create != nil {
+		res.Create = without(res.Create)
+	}
+	//nolint:staticcheck
+	if res.Read != nil {
+		res.Read = without(res.Read)
+	}
+	//nolint:staticcheck
+	if res.Update != nil {
+		res.Update = without(res.Update)
+	}
+}
+
+// managedRules records the rules d configures, or has in its state, and returns a function
+// dropping the other rules from d, once the upstream provider has read the policy into it.
+func managedRules(d *schema.ResourceData) (keep func() error) {
+	if !d.Get("ignore_unmanaged_rules").(bool) {
+		return func() error { return nil }
+	}
+	ids, names := map[string]bool{}, map[string]bool{}
+	for _, r := range d.Get("rule").([]interface{}) {
+		rule, _ := r.(map[string]interface{})
+		if id, _ := rule["nsx_id"].(string); id != "" {
+			ids[id] = true
+		} else if name, _ := rule["display_name"].(string); name != "" {
+			names[name] = true
+		}
+	}
+	return func() error {
+		if d.Id() == "" {
+			return nil
+		}
+		kept := []interface{}{}
+		for _, r := range d.Get("rule").([]interface{}) {
+			rule, _ := r.(map[string]interface{})
+			id, _ := rule["nsx_id"].(string)
+			name, _ := rule["display_name"].(string)
+			if ids[id] || names[name] {
+				kept = append(kept, r)
+			}
+		}
+		return d.Set("rule", kept)
+	}
+}
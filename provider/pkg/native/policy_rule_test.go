@@ -0,0 +1,272 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+const testPolicyPath = "/infra/domains/default/security-policies/shared"
+
+// policyAPI stands in for the Policy API of a security policy and its rules.
+type policyAPI struct {
+	mu    sync.Mutex
+	rules map[string]map[string]interface{}
+}
+
+func (p *policyAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/policy/api/v1")
+	var body map[string]interface{}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	switch id, isRule := strings.CutPrefix(path, testPolicyPath+"/rules/"); {
+	case path == testPolicyPath && r.Method == http.MethodGet:
+		rules := []interface{}{}
+		for _, rule := range p.rules {
+			rules = append(rules, rule)
+		}
+		sort.Slice(rules, func(i, j int) bool {
+			return rules[i].(map[string]interface{})["id"].(string) < rules[j].(map[string]interface{})["id"].(string)
+		})
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": "shared", "rules": rules})
+	case path == testPolicyPath && r.Method == http.MethodPatch:
+		rules, _ := body["rules"].([]interface{})
+		for _, r := range rules {
+			rule := r.(map[string]interface{})
+			if deleted, _ := rule["marked_for_delete"].(bool); deleted {
+				delete(p.rules, rule["id"].(string))
+			} else {
+				p.rules[rule["id"].(string)] = rule
+			}
+		}
+	case isRule && r.Method == http.MethodGet:
+		rule, ok := p.rules[id]
+		if !ok {
+			http.Error(w, `{"error_code":500090}`, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(rule)
+	case isRule && r.Method == http.MethodPatch:
+		body["_revision"] = 1
+		p.rules[id] = body
+	case isRule && r.Method == http.MethodDelete:
+		delete(p.rules, id)
+	default:
+		http.Error(w, "unexpected "+r.Method+" "+path, http.StatusBadRequest)
+	}
+}
+
+func (p *policyAPI) ids() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var ids []string
+	for id := range p.rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestPolicyRule(t *testing.T) {
+	api := &policyAPI{rules: map[string]map[string]interface{}{}}
+	conn := testConnection(t, api.ServeHTTP)
+	res := resourcePolicyRule(conn, false)
+	ctx := context.Background()
+
+	d := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{
+		"policy_path":     testPolicyPath,
+		"nsx_id":          "team",
+		"display_name":    "team",
+		"action":          "ALLOW",
+		"sequence_number": 100,
+	})
+	if diags := res.CreateContext(ctx, d, nil); diags.HasError() {
+		t.Fatal(diags)
+	}
+	if want := testPolicyPath + "/rules/team"; d.Id() != want {
+		t.Errorf("ID = %q, want %q", d.Id(), want)
+	}
+	if got := d.Get("sequence_number"); got != 100 {
+		t.Errorf("sequence_number = %v, want 100", got)
+	}
+
+	imported := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{})
+	imported.SetId(d.Id())
+	if _, err := res.Importer.StateContext(ctx, imported, nil); err != nil {
+		t.Fatal(err)
+	}
+	if diags := res.ReadContext(ctx, imported, nil); diags.HasError() {
+		t.Fatal(diags)
+	}
+	for _, k := range []string{"policy_path", "nsx_id", "display_name", "action", "sequence_number"} {
+		if got, want := imported.Get(k), d.Get(k); got != want {
+			t.Errorf("imported %s = %v, want %v", k, got, want)
+		}
+	}
+
+	bad := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{})
+	bad.SetId("/infra/domains/default/gateway-policies/edge/rules/team")
+	if _, err := res.Importer.StateContext(ctx, bad, nil); err == nil {
+		t.Error("imported a gateway policy rule as a security policy rule")
+	}
+
+	if diags := res.DeleteContext(ctx, d, nil); diags.HasError() {
+		t.Fatal(diags)
+	}
+	if ids := api.ids(); len(ids) != 0 {
+		t.Errorf("rules after delete = %v, want none", ids)
+	}
+}
+
+// upstreamPolicy mimics the policy resources of the upstream provider: an update sends the rules
+// configured and marks those gone from the state for deletion, and a read sets every rule of the
+// policy.
+func upstreamPolicy(t *testing.T, api *policyAPI) *schema.Resource {
+	conn := testConnection(t, api.ServeHTTP)
+	read := func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+		c, diags := client(conn)
+		if diags != nil {
+			return diags
+		}
+		var policy map[string]interface{}
+		if err := c.Do(ctx, "GET", c.PolicyAPI(testPolicyPath), nil, &policy); err != nil {
+			return diag.FromErr(err)
+		}
+		var rules []interface{}
+		for _, r := range policy["rules"].([]interface{}) {
+			rule := r.(map[string]interface{})
+			rules = append(rules, map[string]interface{}{"nsx_id": rule["id"], "display_name": rule["display_name"]})
+		}
+		return diag.FromErr(d.Set("rule", rules))
+	}
+	return &schema.Resource{
+		ReadContext: read,
+		UpdateContext: func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+			c, diags := client(conn)
+			if diags != nil {
+				return diags
+			}
+			old, _ := d.GetChange("rule")
+			kept := map[string]bool{}
+			var rules []interface{}
+			for _, r := range d.Get("rule").([]interface{}) {
+				rule := r.(map[string]interface{})
+				id := rule["nsx_id"].(string)
+				if id == "" {
+					id = rule["display_name"].(string)
+				}
+				kept[id] = true
+				rules = append(rules, map[string]interface{}{"id": id, "display_name": rule["display_name"]})
+			}
+			for _, r := range old.([]interface{}) {
+				if id := r.(map[string]interface{})["nsx_id"].(string); !kept[id] {
+					rules = append(rules, map[string]interface{}{"id": id, "marked_for_delete": true})
+				}
+			}
+			if err := c.Do(ctx, "PATCH", c.PolicyAPI(testPolicyPath), map[string]interface{}{"rules": rules}, nil); err != nil {
+				return diag.FromErr(err)
+			}
+			return read(ctx, d, meta)
+		},
+		Schema: map[string]*schema.Schema{
+			"rule": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{Schema: map[string]*schema.Schema{
+					"nsx_id":       {Type: schema.TypeString, Optional: true, Computed: true},
+					"display_name": {Type: schema.TypeString, Required: true},
+				}},
+			},
+		},
+	}
+}
+
+func TestIgnoreUnmanagedRules(t *testing.T) {
+	rule := func(id string) map[string]interface{} {
+		return map[string]interface{}{"id": id, "display_name": id}
+	}
+	tests := []struct {
+		name      string
+		ignore    bool
+		wantState []string
+		wantRules []string
+	}{
+		{name: "ignored", ignore: true, wantState: []string{"db", "web"}, wantRules: []string{"db", "team", "web"}},
+		// Without it, the update deletes the rule it read but isn't configured with.
+		{name: "managed", wantState: []string{"db", "web"}, wantRules: []string{"db", "web"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &policyAPI{rules: map[string]map[string]interface{}{"web": rule("web"), "team": rule("team")}}
+			res := upstreamPolicy(t, api)
+			IgnoreUnmanagedRules(res)
+			ctx := context.Background()
+
+			d := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{
+				"ignore_unmanaged_rules": tt.ignore,
+				"rule":                   []interface{}{map[string]interface{}{"nsx_id": "web", "display_name": "web"}},
+			})
+			d.SetId("shared")
+			if diags := res.ReadContext(ctx, d, nil); diags.HasError() {
+				t.Fatal(diags)
+			}
+
+			// The parent policy gains a rule, next to the one owned by the standalone resource.
+			config := terraform.NewResourceConfigRaw(map[string]interface{}{
+				"ignore_unmanaged_rules": tt.ignore,
+				"rule": []interface{}{
+					map[string]interface{}{"nsx_id": "web", "display_name": "web"},
+					map[string]interface{}{"nsx_id": "db", "display_name": "db"},
+				},
+			})
+			state := d.State()
+			instanceDiff, err := res.Diff(ctx, state, config, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			state, diags := res.Apply(ctx, state, instanceDiff, nil)
+			if diags.HasError() {
+				t.Fatal(diags)
+			}
+
+			var names []string
+			applied := res.Data(state)
+			for _, r := range applied.Get("rule").([]interface{}) {
+				names = append(names, r.(map[string]interface{})["nsx_id"].(string))
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.wantState) {
+				t.Errorf("rules in state = %v, want %v", names, tt.wantState)
+			}
+			if ids := api.ids(); !reflect.DeepEqual(ids, tt.wantRules) {
+				t.Errorf("rules in NSX = %v, want %v", ids, tt.wantRules)
+			}
+		})
+	}
+}
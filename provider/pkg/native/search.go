@@ -0,0 +1,185 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+func dataSourceSearch(conn *nsxapi.Connection) *schema.Resource {
+	return &schema.Resource{
+		Description: "Finds Policy objects with the NSX search API, across all pages of results.",
+		ReadContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			c, diags := client(conn)
+			if diags != nil {
+				return diags
+			}
+			tags := expandTags(d.Get("tag").([]interface{}))
+			query := searchQuery(d.Get("resource_type").(string), tags, d.Get("display_name").(string),
+				d.Get("parent_path").(string), d.Get("query").(string))
+			if query == "" {
+				return diag.Errorf("at least one of resourceType, tags, displayName, parentPath or query is required")
+			}
+
+			objects, err := c.List(ctx, c.PolicyAPI("/search/query?query="+url.QueryEscape(query)))
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			results := make([]interface{}, 0, len(objects))
+			for _, obj := range objects {
+				// The search matches scopes and values separately, pairs are checked here.
+				if !hasTags(obj, tags) {
+					continue
+				}
+				raw, err := marshal(obj)
+				if err != nil {
+					return diag.FromErr(err)
+				}
+				results = append(results, map[string]interface{}{
+					"path":          stringField(obj, "path"),
+					"id":            stringField(obj, "id"),
+					"display_name":  stringField(obj, "display_name"),
+					"resource_type": stringField(obj, "resource_type"),
+					"tag":           flattenTags(tagsOf(obj)),
+					"json":          raw,
+				})
+			}
+			d.SetId(query)
+			return diag.FromErr(SetAll(d, map[string]interface{}{
+				"results": results,
+			}))
+		},
+		Schema: map[string]*schema.Schema{
+			"resource_type": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Type of the objects, such as Segment, Group or Tier1",
+			},
+			"tag": tagFilterSchema(),
+			"display_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Display name of the objects, with `*` and `?` wildcards",
+			},
+			"parent_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Policy path of the parent of the objects, such as /infra/domains/default",
+			},
+			"query": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Description: "Additional condition in the NSX search syntax, such as " +
+					"`marked_for_delete:false`, combined with the others",
+			},
+			"results": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Objects found",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"path": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Policy path of the object",
+						},
+						"id": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "ID of the object",
+						},
+						"display_name": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Display name of the object",
+						},
+						"resource_type": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Type of the object",
+						},
+						"tag": tagSchema(),
+						"json": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "JSON of the object as returned by NSX",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// searchQuery builds a search query matching all the given conditions, the empty ones excepted.
+func searchQuery(resourceType string, tags []tag, displayName, parentPath, extra string) string {
+	var terms []string
+	if resourceType != "" {
+		terms = append(terms, "resource_type:"+escapeSearch(resourceType, false))
+	}
+	for _, t := range tags {
+		if t.Scope != "" {
+			terms = append(terms, "tags.scope:"+quoteSearch(t.Scope))
+		}
+		if t.Tag != "" {
+			terms = append(terms, "tags.tag:"+quoteSearch(t.Tag))
+		}
+	}
+	if displayName != "" {
+		terms = append(terms, "display_name:"+escapeSearch(displayName, true))
+	}
+	if parentPath != "" {
+		terms = append(terms, "parent_path:"+quoteSearch(parentPath))
+	}
+	if extra != "" {
+		terms = append(terms, "("+extra+")")
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// searchSpecial are the characters of the search syntax, which values must escape.
+const searchSpecial = `+-&|!(){}[]^"~*?:\/ `
+
+// escapeSearch escapes the special characters of a search value, but for the * and ? wildcards
+// when wildcards is set.
+func escapeSearch(value string, wildcards bool) string {
+	var b strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune(searchSpecial, r) && !(wildcards && (r == '*' || r == '?')) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// quoteSearch quotes a search value so that it is matched as a whole.
+func quoteSearch(value string) string {
+	return fmt.Sprintf(`"%s"`, strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value))
+}
+
+// stringField returns the string field key of obj, or "".
+func stringField(obj map[string]interface{}, key string) string {
+	s, _ := obj[key].(string)
+	return s
+}
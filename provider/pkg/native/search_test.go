@@ -0,0 +1,168 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestSearchQuery(t *testing.T) {
+	tests := []struct {
+		name         string
+		resourceType string
+		tags         []tag
+		displayName  string
+		parentPath   string
+		extra        string
+		want         string
+	}{
+		{
+			name: "no filter",
+			want: "",
+		},
+		{
+			name: "empty tag",
+			tags: []tag{{}},
+			want: "",
+		},
+		{
+			name:         "resource type",
+			resourceType: "Group",
+			want:         "resource_type:Group",
+		},
+		{
+			name: "tags",
+			tags: []tag{{Scope: "env", Tag: "prod"}, {Scope: "team"}, {Tag: "web"}},
+			want: `tags.scope:"env" AND tags.tag:"prod" AND tags.scope:"team" AND tags.tag:"web"`,
+		},
+		{
+			name: "tags with quotes and backslashes",
+			tags: []tag{{Scope: `a"b`, Tag: `c\d`}},
+			want: `tags.scope:"a\"b" AND tags.tag:"c\\d"`,
+		},
+		{
+			name:        "display name with wildcards and special characters",
+			displayName: "web (prod)-*?",
+			want:        `display_name:web\ \(prod\)\-*?`,
+		},
+		{
+			name:       "parent path",
+			parentPath: "/infra/domains/default",
+			want:       `parent_path:"/infra/domains/default"`,
+		},
+		{
+			name:         "all",
+			resourceType: "Segment",
+			tags:         []tag{{Scope: "env", Tag: "prod"}},
+			displayName:  "web",
+			parentPath:   "/infra",
+			extra:        "admin_state:UP OR admin_state:DOWN",
+			want: `resource_type:Segment AND tags.scope:"env" AND tags.tag:"prod" AND display_name:web AND ` +
+				`parent_path:"/infra" AND (admin_state:UP OR admin_state:DOWN)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := searchQuery(tt.resourceType, tt.tags, tt.displayName, tt.parentPath, tt.extra)
+			if got != tt.want {
+				t.Errorf("searchQuery() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeSearch(t *testing.T) {
+	tests := []struct {
+		value     string
+		wildcards bool
+		want      string
+	}{
+		{"web", false, "web"},
+		{"", false, ""},
+		{"a*b?c", false, `a\*b\?c`},
+		{"a*b?c", true, "a*b?c"},
+		{`+-&|!(){}[]^"~:\/ `, true, `\+\-\&\|\!\(\)\{\}\[\]\^\"\~\:\\\/\ `},
+		{"été", false, "été"},
+	}
+	for _, tt := range tests {
+		if got := escapeSearch(tt.value, tt.wildcards); got != tt.want {
+			t.Errorf("escapeSearch(%q, %v) = %s, want %s", tt.value, tt.wildcards, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteSearch(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"prod", `"prod"`},
+		{"", `""`},
+		{"a b*:c", `"a b*:c"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\dir\`, `"C:\\dir\\"`},
+	}
+	for _, tt := range tests {
+		if got := quoteSearch(tt.value); got != tt.want {
+			t.Errorf("quoteSearch(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	var queries []string
+	conn := testConnection(t, func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("query"))
+		_, _ = w.Write([]byte(`{"results": [
+			{"path": "/infra/tags/a", "id": "a", "tags": [{"scope": "env", "tag": "prod"}]},
+			{"path": "/infra/tags/b", "id": "b", "tags": [{"scope": "env", "tag": "dev"}, {"scope": "x", "tag": "prod"}]}
+		], "result_count": 2}`))
+	})
+	res := dataSourceSearch(conn)
+
+	t.Run("no filter", func(t *testing.T) {
+		d := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{})
+		diags := res.ReadContext(context.Background(), d, nil)
+		if !diags.HasError() || !strings.Contains(diags[0].Summary, "at least one of") {
+			t.Errorf("Read() = %v, want an error", diags)
+		}
+		if len(queries) != 0 {
+			t.Errorf("queries = %q, want none", queries)
+		}
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		d := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{
+			"display_name": `a&b "c"`,
+			"tag":          []interface{}{map[string]interface{}{"scope": "env", "tag": "prod"}},
+		})
+		if diags := res.ReadContext(context.Background(), d, nil); diags.HasError() {
+			t.Fatal(diags)
+		}
+		want := `tags.scope:"env" AND tags.tag:"prod" AND display_name:a\&b\ \"c\"`
+		if len(queries) != 1 || queries[0] != want {
+			t.Errorf("queries = %q, want %q", queries, want)
+		}
+		// b only has the scope and the tag in different pairs.
+		if results := d.Get("results").([]interface{}); len(results) != 1 ||
+			results[0].(map[string]interface{})["id"] != "a" {
+			t.Errorf("results = %v, want a only", results)
+		}
+	})
+}
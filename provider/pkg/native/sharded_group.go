@@ -0,0 +1,273 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/shard"
+)
+
+// maxIPAddressesPerExpression is the limit of NSX on the IP addresses of an IP address expression.
+const maxIPAddressesPerExpression = 4000
+
+func resourceShardedGroup(conn *nsxapi.Connection) *schema.Resource {
+	return &schema.Resource{
+		Description: "Manages a group of IP addresses of any size, split into child groups holding at most " +
+			"shardSize addresses each and nested in the group.",
+		CreateContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			if diags := applyShardedGroup(ctx, conn, d); diags != nil {
+				return diags
+			}
+			d.SetId(groupPath(d.Get("domain").(string), d.Get("nsx_id").(string)))
+			return readShardedGroup(ctx, conn, d)
+		},
+		ReadContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			return readShardedGroup(ctx, conn, d)
+		},
+		UpdateContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			if diags := applyShardedGroup(ctx, conn, d); diags != nil {
+				return diags
+			}
+			return readShardedGroup(ctx, conn, d)
+		},
+		DeleteContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			c, diags := client(conn)
+			if diags != nil {
+				return diags
+			}
+			if err := c.Do(ctx, "DELETE", c.PolicyAPI(d.Id()), nil, nil); err != nil && !nsxapi.IsNotFound(err) {
+				return diag.FromErr(err)
+			}
+			nsxID := d.Get("nsx_id").(string)
+			var children []interface{}
+			for _, s := range expandShards(d.Get("shard"), "ip_addresses") {
+				children = append(children, child("Group", map[string]interface{}{"id": shardID(nsxID, s.ID)}, true))
+			}
+			return diag.FromErr(patchDomain(ctx, c, d.Get("domain").(string), children))
+		},
+		CustomizeDiff: func(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+			return planShards(d, "ip_addresses", "ip_addresses", func(previous []shard.Shard) ([]shard.Shard, error) {
+				return shard.Set(previous, stringSet(d.Get("ip_addresses")), d.Get("shard_size").(int)), nil
+			})
+		},
+		Schema: map[string]*schema.Schema{
+			"nsx_id": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				Description:  "NSX ID of the group, its shards are named after it",
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+			},
+			"domain": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "default",
+				Description: "Domain of the group",
+			},
+			"display_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Display name of the group",
+			},
+			"description": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Description of the group",
+			},
+			"tag": tagInputSchema(),
+			"ip_addresses": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "IP addresses, networks and ranges of the group",
+			},
+			"shard_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      maxIPAddressesPerExpression,
+				Description:  "Maximum number of IP addresses per child group",
+				ValidateFunc: validation.IntBetween(1, maxIPAddressesPerExpression),
+			},
+			"path": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Policy path of the group",
+			},
+			"shard": shardSchema("ip_addresses", "IP addresses of the shard"),
+		},
+	}
+}
+
+func groupPath(domain, id string) string {
+	return fmt.Sprintf("/infra/domains/%s/groups/%s", domain, id)
+}
+
+// shardedGroupObjects returns the objects of a sharded group for the values of get, the new or
+// the old ones: the group first, then its shards by shard ID.
+func shardedGroupObjects(get func(string) interface{}) (map[string]interface{}, map[int]map[string]interface{}) {
+	nsxID, domain := get("nsx_id").(string), get("domain").(string)
+	name, description := get("display_name").(string), get("description").(string)
+	tags := flattenTags(expandTags(get("tag").([]interface{})))
+
+	shards := map[int]map[string]interface{}{}
+	var paths []string
+	for _, s := range expandShards(get("shard"), "ip_addresses") {
+		id := shardID(nsxID, s.ID)
+		paths = append(paths, groupPath(domain, id))
+		shards[s.ID] = map[string]interface{}{
+			"id":           id,
+			"display_name": fmt.Sprintf("%s - shard %d", name, s.ID),
+			"description":  description,
+			"tags":         tags,
+			"expression": []interface{}{
+				map[string]interface{}{"resource_type": "IPAddressExpression", "ip_addresses": s.Items},
+			},
+		}
+	}
+
+	expression := []interface{}{}
+	if len(paths) > 0 {
+		expression = append(expression, map[string]interface{}{"resource_type": "PathExpression", "member_paths": paths})
+	}
+	group := map[string]interface{}{
+		"id":           nsxID,
+		"display_name": name,
+		"description":  description,
+		"tags":         tags,
+		"expression":   expression,
+	}
+	return group, shards
+}
+
+// applyShardedGroup creates or updates the shards that changed or drifted and the group, then
+// deletes the shards no longer needed once the group doesn't refer to them anymore.
+func applyShardedGroup(ctx context.Context, conn *nsxapi.Connection, d *schema.ResourceData) diag.Diagnostics {
+	c, diags := client(conn)
+	if diags != nil {
+		return diags
+	}
+	oldGroup, oldShards := shardedGroupObjects(func(k string) interface{} {
+		old, _ := d.GetChange(k)
+		return old
+	})
+	group, shards := shardedGroupObjects(d.Get)
+	domain := d.Get("domain").(string)
+	old, _ := d.GetChange("shard")
+	drift := driftedShards(old)
+
+	var children []interface{}
+	for id, s := range shards {
+		if d.IsNewResource() || drift[id] || !reflect.DeepEqual(oldShards[id], s) {
+			children = append(children, child("Group", s, false))
+		}
+	}
+	if d.IsNewResource() || !reflect.DeepEqual(oldGroup, group) {
+		children = append(children, child("Group", group, false))
+	}
+	if err := patchDomain(ctx, c, domain, children); err != nil {
+		return diag.FromErr(err)
+	}
+
+	var deleted []interface{}
+	for id, s := range oldShards {
+		if _, ok := shards[id]; !ok {
+			deleted = append(deleted, child("Group", map[string]interface{}{"id": s["id"]}, true))
+		}
+	}
+	return diag.FromErr(patchDomain(ctx, c, domain, deleted))
+}
+
+// readShardedGroup reads the group and the addresses of its shards. Shards that are missing or
+// differ from the resource otherwise are marked as drifted for the next update to rewrite them.
+func readShardedGroup(ctx context.Context, conn *nsxapi.Connection, d *schema.ResourceData) diag.Diagnostics {
+	c, diags := client(conn)
+	if diags != nil {
+		return diags
+	}
+	var group map[string]interface{}
+	if err := c.Do(ctx, "GET", c.PolicyAPI(d.Id()), nil, &group); err != nil {
+		if nsxapi.IsNotFound(err) {
+			d.SetId("")
+			return nil
+		}
+		return diag.FromErr(err)
+	}
+
+	nsxID, domain := d.Get("nsx_id").(string), d.Get("domain").(string)
+	_, objects := shardedGroupObjects(d.Get)
+	var shards []shard.Shard
+	drift := map[int]bool{}
+	for _, s := range expandShards(d.Get("shard"), "ip_addresses") {
+		var obj map[string]interface{}
+		err := c.Do(ctx, "GET", c.PolicyAPI(groupPath(domain, shardID(nsxID, s.ID))), nil, &obj)
+		if nsxapi.IsNotFound(err) {
+			shards = append(shards, s)
+			drift[s.ID] = true
+			continue
+		}
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		addresses, ok := ipAddressesOf(obj)
+		want := map[string]interface{}{}
+		for k, v := range objects[s.ID] {
+			if k != "expression" {
+				want[k] = v
+			}
+		}
+		drift[s.ID] = !ok || drifted(want, obj)
+		shards = append(shards, shard.Shard{ID: s.ID, Items: addresses})
+	}
+	return diag.FromErr(SetAll(d, map[string]interface{}{
+		"display_name": stringField(group, "display_name"),
+		"description":  stringField(group, "description"),
+		"tag":          flattenTags(tagsOf(group)),
+		"path":         d.Id(),
+		"shard":        flattenShards(shards, "ip_addresses", drift),
+	}))
+}
+
+// ipAddressesOf returns the addresses of the IP address expressions of a group, sorted, and
+// whether the group has no other expressions.
+func ipAddressesOf(group map[string]interface{}) ([]string, bool) {
+	expressions, _ := group["expression"].([]interface{})
+	var addresses []string
+	ok := true
+	for _, e := range expressions {
+		expression, _ := e.(map[string]interface{})
+		if stringField(expression, "resource_type") != "IPAddressExpression" {
+			ok = false
+			continue
+		}
+		list, _ := expression["ip_addresses"].([]interface{})
+		for _, a := range list {
+			if address, _ := a.(string); address != "" {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	sort.Strings(addresses)
+	return addresses, ok
+}
@@ -0,0 +1,518 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/shard"
+)
+
+// maxRulesPerPolicy is the limit of NSX on the rules of a security policy.
+const maxRulesPerPolicy = 1000
+
+// ruleSequenceGap is the gap between the sequence numbers of new rules, leaving room to insert
+// rules without renumbering the others.
+const ruleSequenceGap = 10
+
+func resourceShardedSecurityPolicy(conn *nsxapi.Connection) *schema.Resource {
+	return &schema.Resource{
+		Description: "Manages a distributed firewall rule set of any size, split into consecutive security " +
+			"policies holding at most rulesPerPolicy rules each.",
+		CreateContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			if diags := applyShardedSecurityPolicy(ctx, conn, d); diags != nil {
+				return diags
+			}
+			d.SetId(d.Get("nsx_id").(string))
+			return readShardedSecurityPolicy(ctx, conn, d)
+		},
+		ReadContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			return readShardedSecurityPolicy(ctx, conn, d)
+		},
+		UpdateContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			if diags := applyShardedSecurityPolicy(ctx, conn, d); diags != nil {
+				return diags
+			}
+			return readShardedSecurityPolicy(ctx, conn, d)
+		},
+		DeleteContext: func(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+			c, diags := client(conn)
+			if diags != nil {
+				return diags
+			}
+			var children []interface{}
+			for _, s := range expandShards(d.Get("shard"), "rule_ids") {
+				policy := map[string]interface{}{"id": shardID(d.Id(), s.ID)}
+				children = append(children, child("SecurityPolicy", policy, true))
+			}
+			return diag.FromErr(patchDomain(ctx, c, d.Get("domain").(string), children))
+		},
+		CustomizeDiff: func(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+			err := planShards(d, "rule", "rule_ids", func(previous []shard.Shard) ([]shard.Shard, error) {
+				ids, err := ruleIDs(d.Get("rule").([]interface{}))
+				if err != nil {
+					return nil, err
+				}
+				return shard.List(previous, ids, d.Get("rules_per_policy").(int)), nil
+			})
+			if err != nil {
+				return err
+			}
+			return planSequenceNumbers(d)
+		},
+		Schema: map[string]*schema.Schema{
+			"nsx_id": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				Description:  "NSX ID the policies are named after",
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+			},
+			"domain": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "default",
+				Description: "Domain of the policies",
+			},
+			"display_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Display name the policies are named after",
+			},
+			"description": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Description of the policies",
+			},
+			"tag": tagInputSchema(),
+			"category": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Category of the policies",
+				ValidateFunc: validation.StringInSlice([]string{
+					"Ethernet", "Emergency", "Infrastructure", "Environment", "Application",
+				}, false),
+			},
+			"sequence_number": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     0,
+				Description: "Sequence number of the first policy, the next ones follow it",
+			},
+			"stateful": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     true,
+				Description: "Whether the policies are stateful",
+			},
+			"tcp_strict": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether TCP connections must start with a handshake",
+			},
+			"scope": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Paths of the groups the policies apply to, everywhere when not set",
+			},
+			"rules_per_policy": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      maxRulesPerPolicy,
+				Description:  "Maximum number of rules per policy",
+				ValidateFunc: validation.IntBetween(1, maxRulesPerPolicy),
+			},
+			"rule": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Rules, in order",
+				Elem:        ruleSchema(),
+			},
+			"paths": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Policy paths of the policies, in order",
+			},
+			"shard": shardSchema("rule_ids", "NSX IDs of the rules of the shard"),
+			"policy_sequence_numbers": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeInt},
+				Description: "Sequence numbers of the policies, by shard ID",
+			},
+			"rule_sequence_numbers": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeInt},
+				Description: "Sequence numbers of the rules in their policy, by rule NSX ID",
+			},
+		},
+	}
+}
+
+// ruleSchema is the schema of a rule, with the fields of the rules of nsxt_policy_security_policy.
+func ruleSchema() *schema.Resource {
+	groups := func(description string) *schema.Schema {
+		return &schema.Schema{
+			Type:        schema.TypeSet,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: description,
+		}
+	}
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"nsx_id": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "NSX ID of the rule, its display name when not set",
+			},
+			"display_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Display name of the rule",
+			},
+			"description": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Description of the rule",
+			},
+			"notes": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Notes of the rule",
+			},
+			"action": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "ALLOW",
+				Description:  "ALLOW, DROP, REJECT or JUMP_TO_APPLICATION",
+				ValidateFunc: validation.StringInSlice([]string{"ALLOW", "DROP", "REJECT", "JUMP_TO_APPLICATION"}, false),
+			},
+			"source_groups":      groups("Paths of the source groups, any source when not set"),
+			"destination_groups": groups("Paths of the destination groups, any destination when not set"),
+			"services":           groups("Paths of the services, any service when not set"),
+			"scope":              groups("Paths of the groups the rule applies to, those of the policy when not set"),
+			"profiles":           groups("Paths of the context profiles"),
+			"direction": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "IN_OUT",
+				Description:  "IN, OUT or IN_OUT",
+				ValidateFunc: validation.StringInSlice([]string{"IN", "OUT", "IN_OUT"}, false),
+			},
+			"ip_version": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "IPV4_IPV6",
+				Description:  "IPV4, IPV6 or IPV4_IPV6",
+				ValidateFunc: validation.StringInSlice([]string{"IPV4", "IPV6", "IPV4_IPV6"}, false),
+			},
+			"logged": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Whether matching flows are logged",
+			},
+			"log_label": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Label of the log entries of the rule",
+			},
+			"disabled": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Whether the rule is disabled",
+			},
+			"sources_excluded": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Whether the rule matches all sources but the source groups",
+			},
+			"destinations_excluded": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Whether the rule matches all destinations but the destination groups",
+			},
+			"tag": tagInputSchema(),
+		},
+	}
+}
+
+// ruleID returns the NSX ID of a rule.
+func ruleID(rule map[string]interface{}) string {
+	if id, _ := rule["nsx_id"].(string); id != "" {
+		return id
+	}
+	return rule["display_name"].(string)
+}
+
+// ruleIDs returns the NSX IDs of rules, which must be unique.
+func ruleIDs(rules []interface{}) ([]string, error) {
+	ids := make([]string, 0, len(rules))
+	seen := map[string]bool{}
+	for _, r := range rules {
+		rule, _ := r.(map[string]interface{})
+		if rule == nil {
+			continue
+		}
+		id := ruleID(rule)
+		if seen[id] {
+			return nil, fmt.Errorf("rule %q appears twice: rules need unique NSX IDs, or display names "+
+				"when nsxId isn't set", id)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// rulePayload returns a rule as NSX expects it.
+func rulePayload(rule map[string]interface{}, sequenceNumber int) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                    ruleID(rule),
+		"display_name":          rule["display_name"],
+		"description":           rule["description"],
+		"notes":                 rule["notes"],
+		"action":                rule["action"],
+		"source_groups":         orAny(stringSet(rule["source_groups"])),
+		"destination_groups":    orAny(stringSet(rule["destination_groups"])),
+		"services":              orAny(stringSet(rule["services"])),
+		"scope":                 orAny(stringSet(rule["scope"])),
+		"profiles":              orAny(stringSet(rule["profiles"])),
+		"direction":             rule["direction"],
+		"ip_protocol":           rule["ip_version"],
+		"logged":                rule["logged"],
+		"tag":                   rule["log_label"],
+		"disabled":              rule["disabled"],
+		"sources_excluded":      rule["sources_excluded"],
+		"destinations_excluded": rule["destinations_excluded"],
+		"tags":                  flattenTags(expandTags(rule["tag"].([]interface{}))),
+		"sequence_number":       sequenceNumber,
+	}
+}
+
+// planSequenceNumbers numbers the policies from sequence_number and the rules of each policy,
+// keeping the previous numbers the order still allows so that a change renumbers few of them.
+func planSequenceNumbers(d *schema.ResourceDiff) error {
+	if !d.NewValueKnown("rule") || !d.NewValueKnown("sequence_number") {
+		if err := d.SetNewComputed("policy_sequence_numbers"); err != nil {
+			return err
+		}
+		return d.SetNewComputed("rule_sequence_numbers")
+	}
+	oldPolicies, _ := d.GetChange("policy_sequence_numbers")
+	oldRules, _ := d.GetChange("rule_sequence_numbers")
+	previousPolicies, previousRules := intMap(oldPolicies), intMap(oldRules)
+	if d.HasChange("sequence_number") {
+		previousPolicies = nil
+	}
+
+	var shardIDs []string
+	rules := map[string]interface{}{}
+	for _, s := range expandShards(d.Get("shard"), "rule_ids") {
+		shardIDs = append(shardIDs, strconv.Itoa(s.ID))
+		for id, n := range shard.Number(previousRules, s.Items, 1, ruleSequenceGap) {
+			rules[id] = n
+		}
+	}
+	policies := map[string]interface{}{}
+	for id, n := range shard.Number(previousPolicies, shardIDs, d.Get("sequence_number").(int), 1) {
+		policies[id] = n
+	}
+	if err := d.SetNew("policy_sequence_numbers", policies); err != nil {
+		return err
+	}
+	return d.SetNew("rule_sequence_numbers", rules)
+}
+
+// intMap returns the values of a map of integers.
+func intMap(v interface{}) map[string]int {
+	m, _ := v.(map[string]interface{})
+	values := make(map[string]int, len(m))
+	for k, item := range m {
+		values[k], _ = item.(int)
+	}
+	return values
+}
+
+// shardedPolicies returns the policies of a sharded rule set for the values of get, the new or the
+// old ones, by shard ID, with their rules by rule ID. Rules of the shards that aren't in the rule
+// set, read from NSX, only have their ID, for the update to delete them.
+func shardedPolicies(get func(string) interface{}) map[int]map[string]interface{} {
+	nsxID, name := get("nsx_id").(string), get("display_name").(string)
+	rules := map[string]map[string]interface{}{}
+	for _, r := range get("rule").([]interface{}) {
+		if rule, _ := r.(map[string]interface{}); rule != nil {
+			rules[ruleID(rule)] = rule
+		}
+	}
+	policyNumbers, ruleNumbers := intMap(get("policy_sequence_numbers")), intMap(get("rule_sequence_numbers"))
+
+	policies := map[int]map[string]interface{}{}
+	for _, s := range expandShards(get("shard"), "rule_ids") {
+		policyRules := map[string]interface{}{}
+		for _, id := range s.Items {
+			if rule, ok := rules[id]; ok {
+				policyRules[id] = rulePayload(rule, ruleNumbers[id])
+			} else {
+				policyRules[id] = map[string]interface{}{"id": id}
+			}
+		}
+		policies[s.ID] = map[string]interface{}{
+			"id":              shardID(nsxID, s.ID),
+			"display_name":    fmt.Sprintf("%s - shard %d", name, s.ID),
+			"description":     get("description"),
+			"tags":            flattenTags(expandTags(get("tag").([]interface{}))),
+			"category":        get("category"),
+			"sequence_number": policyNumbers[strconv.Itoa(s.ID)],
+			"stateful":        get("stateful"),
+			"tcp_strict":      get("tcp_strict"),
+			"scope":           orAny(stringSet(get("scope"))),
+			"rules":           policyRules,
+		}
+	}
+	return policies
+}
+
+// applyShardedSecurityPolicy creates, updates and deletes the policies and rules that changed, in
+// a single hierarchical call. Drifted policies are rewritten with all their rules.
+func applyShardedSecurityPolicy(ctx context.Context, conn *nsxapi.Connection, d *schema.ResourceData) diag.Diagnostics {
+	c, diags := client(conn)
+	if diags != nil {
+		return diags
+	}
+	oldPolicies := shardedPolicies(func(k string) interface{} {
+		old, _ := d.GetChange(k)
+		return old
+	})
+	policies := shardedPolicies(d.Get)
+	oldShards, _ := d.GetChange("shard")
+	drift := driftedShards(oldShards)
+
+	var children []interface{}
+	for id, policy := range policies {
+		old := oldPolicies[id]
+		rewrite := d.IsNewResource() || drift[id]
+		if !rewrite && reflect.DeepEqual(old, policy) {
+			continue
+		}
+		rules := policy["rules"].(map[string]interface{})
+		var oldRules map[string]interface{}
+		if old != nil {
+			oldRules = old["rules"].(map[string]interface{})
+		}
+		ruleChildren := []interface{}{}
+		for ruleID, rule := range rules {
+			if rewrite || !reflect.DeepEqual(oldRules[ruleID], rule) {
+				ruleChildren = append(ruleChildren, child("Rule", rule.(map[string]interface{}), false))
+			}
+		}
+		for ruleID := range oldRules {
+			if _, ok := rules[ruleID]; !ok {
+				ruleChildren = append(ruleChildren, child("Rule", map[string]interface{}{"id": ruleID}, true))
+			}
+		}
+		payload := map[string]interface{}{"children": ruleChildren}
+		for k, v := range policy {
+			if k != "rules" {
+				payload[k] = v
+			}
+		}
+		children = append(children, child("SecurityPolicy", payload, false))
+	}
+	for id, old := range oldPolicies {
+		if _, ok := policies[id]; !ok {
+			children = append(children, child("SecurityPolicy", map[string]interface{}{"id": old["id"]}, true))
+		}
+	}
+	return diag.FromErr(patchDomain(ctx, c, d.Get("domain").(string), children))
+}
+
+// readShardedSecurityPolicy reads the rules of the policies and marks the policies that differ
+// from the resource, or are missing, as drifted for the next update to rewrite them. The
+// resource is gone when all of them are.
+func readShardedSecurityPolicy(ctx context.Context, conn *nsxapi.Connection, d *schema.ResourceData) diag.Diagnostics {
+	c, diags := client(conn)
+	if diags != nil {
+		return diags
+	}
+	domain := d.Get("domain").(string)
+	all := expandShards(d.Get("shard"), "rule_ids")
+	policies := shardedPolicies(d.Get)
+	shards := make([]shard.Shard, 0, len(all))
+	drift := map[int]bool{}
+	var paths []string
+	missing := 0
+	for _, s := range all {
+		path := fmt.Sprintf("/infra/domains/%s/security-policies/%s", domain, shardID(d.Id(), s.ID))
+		paths = append(paths, path)
+		var policy map[string]interface{}
+		err := c.Do(ctx, "GET", c.PolicyAPI(path), nil, &policy)
+		if nsxapi.IsNotFound(err) {
+			shards = append(shards, s)
+			drift[s.ID] = true
+			missing++
+			continue
+		}
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		rules, err := c.List(ctx, c.PolicyAPI(path+"/rules"))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		sort.SliceStable(rules, func(i, j int) bool {
+			a, _ := rules[i]["sequence_number"].(float64)
+			b, _ := rules[j]["sequence_number"].(float64)
+			return a < b
+		})
+
+		want := policies[s.ID]
+		wantRules := want["rules"].(map[string]interface{})
+		read := shard.Shard{ID: s.ID}
+		drift[s.ID] = drifted(want, policy)
+		for _, rule := range rules {
+			id := stringField(rule, "id")
+			read.Items = append(read.Items, id)
+			if w, ok := wantRules[id].(map[string]interface{}); ok && drifted(w, rule) {
+				drift[s.ID] = true
+			}
+		}
+		shards = append(shards, read)
+	}
+	if len(all) > 0 && missing == len(all) {
+		d.SetId("")
+		return nil
+	}
+	return diag.FromErr(SetAll(d, map[string]interface{}{
+		"paths": paths,
+		"shard": flattenShards(shards, "rule_ids", drift),
+	}))
+}
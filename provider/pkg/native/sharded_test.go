@@ -0,0 +1,186 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// serve returns a handler serving the bodies of served by Policy API path, and 404 for the others.
+func serve(served map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, ok := served[strings.TrimPrefix(r.URL.Path, "/policy/api/v1")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, body)
+	}
+}
+
+// shardsOf returns the items and drift of the shards of d, by shard ID.
+func shardsOf(d *schema.ResourceData, itemsField string) (map[int][]string, map[int]bool) {
+	items := map[int][]string{}
+	for _, s := range expandShards(d.Get("shard"), itemsField) {
+		items[s.ID] = s.Items
+	}
+	return items, driftedShards(d.Get("shard"))
+}
+
+func TestReadShardedGroup(t *testing.T) {
+	const groups = "/infra/domains/default/groups/"
+	conn := testConnection(t, serve(map[string]string{
+		groups + "big": `{"id":"big","display_name":"big"}`,
+		groups + "big-shard-0": `{"id":"big-shard-0","display_name":"big - shard 0","_revision":3,"expression":[
+			{"resource_type":"IPAddressExpression","id":"e","ip_addresses":["10.0.0.1"]}]}`,
+		groups + "big-shard-1": `{"id":"big-shard-1","display_name":"renamed","expression":[
+			{"resource_type":"IPAddressExpression","ip_addresses":["10.0.0.3"]}]}`,
+	}))
+	res := resourceShardedGroup(conn)
+	d := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{
+		"nsx_id":       "big",
+		"display_name": "big",
+		"ip_addresses": []interface{}{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"},
+	})
+	d.SetId(groupPath("default", "big"))
+	if err := d.Set("shard", []interface{}{
+		map[string]interface{}{"id": 0, "ip_addresses": []interface{}{"10.0.0.1", "10.0.0.2"}},
+		map[string]interface{}{"id": 1, "ip_addresses": []interface{}{"10.0.0.3"}},
+		map[string]interface{}{"id": 2, "ip_addresses": []interface{}{"10.0.0.4"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if diags := res.ReadContext(context.Background(), d, nil); diags.HasError() {
+		t.Fatal(diags)
+	}
+	items, drift := shardsOf(d, "ip_addresses")
+	wantItems := map[int][]string{0: {"10.0.0.1"}, 1: {"10.0.0.3"}, 2: {"10.0.0.4"}}
+	if !reflect.DeepEqual(items, wantItems) {
+		t.Errorf("shard addresses = %v, want %v", items, wantItems)
+	}
+	if wantDrift := map[int]bool{1: true, 2: true}; !reflect.DeepEqual(drift, wantDrift) {
+		t.Errorf("drifted shards = %v, want %v", drift, wantDrift)
+	}
+}
+
+func TestReadShardedSecurityPolicy(t *testing.T) {
+	const policies = "/infra/domains/default/security-policies/"
+	policy := func(id int) string {
+		return fmt.Sprintf(`{"id":"fw-shard-%d","display_name":"fw - shard %d","category":"Application",`+
+			`"sequence_number":%d,"stateful":true,"scope":["ANY"],"_revision":1}`, id, id, id)
+	}
+	rule := func(id, action string, sequence string) string {
+		return `{"id":"` + id + `","display_name":"` + id + `","action":"` + action + `","sequence_number":` +
+			sequence + `,"source_groups":["ANY"],"destination_groups":["ANY"],"services":["ANY"],` +
+			`"scope":["ANY"],"profiles":["ANY"],"direction":"IN_OUT","ip_protocol":"IPV4_IPV6","logged":false}`
+	}
+	tests := []struct {
+		name      string
+		served    map[string]string
+		gone      bool
+		wantItems map[int][]string
+		wantDrift map[int]bool
+	}{
+		{
+			name: "in sync",
+			served: map[string]string{
+				policies + "fw-shard-0":       policy(0),
+				policies + "fw-shard-0/rules": `{"results":[` + rule("b", "DROP", "20") + `,` + rule("a", "ALLOW", "10") + `]}`,
+				policies + "fw-shard-1":       policy(1),
+				policies + "fw-shard-1/rules": `{"results":[` + rule("c", "ALLOW", "10") + `]}`,
+			},
+			wantItems: map[int][]string{0: {"a", "b"}, 1: {"c"}},
+			wantDrift: map[int]bool{},
+		},
+		{
+			name: "rule changed and policy missing",
+			served: map[string]string{
+				policies + "fw-shard-0":       policy(0),
+				policies + "fw-shard-0/rules": `{"results":[` + rule("a", "ALLOW", "10") + `,` + rule("b", "ALLOW", "20") + `]}`,
+			},
+			wantItems: map[int][]string{0: {"a", "b"}, 1: {"c"}},
+			wantDrift: map[int]bool{0: true, 1: true},
+		},
+		{
+			name: "rules added and deleted",
+			served: map[string]string{
+				policies + "fw-shard-0":       policy(0),
+				policies + "fw-shard-0/rules": `{"results":[` + rule("a", "ALLOW", "10") + `]}`,
+				policies + "fw-shard-1":       policy(1),
+				policies + "fw-shard-1/rules": `{"results":[` + rule("c", "ALLOW", "10") + `,` + rule("x", "ALLOW", "5") + `]}`,
+			},
+			wantItems: map[int][]string{0: {"a"}, 1: {"x", "c"}},
+			wantDrift: map[int]bool{},
+		},
+		{
+			name:   "all policies missing",
+			served: map[string]string{},
+			gone:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := resourceShardedSecurityPolicy(testConnection(t, serve(tt.served)))
+			d := schema.TestResourceDataRaw(t, res.Schema, map[string]interface{}{
+				"nsx_id":       "fw",
+				"display_name": "fw",
+				"category":     "Application",
+				"rule": []interface{}{
+					map[string]interface{}{"display_name": "a"},
+					map[string]interface{}{"display_name": "b", "action": "DROP"},
+					map[string]interface{}{"display_name": "c"},
+				},
+			})
+			d.SetId("fw")
+			err := SetAll(d, map[string]interface{}{
+				"shard": []interface{}{
+					map[string]interface{}{"id": 0, "rule_ids": []interface{}{"a", "b"}},
+					map[string]interface{}{"id": 1, "rule_ids": []interface{}{"c"}},
+				},
+				"policy_sequence_numbers": map[string]interface{}{"0": 0, "1": 1},
+				"rule_sequence_numbers":   map[string]interface{}{"a": 10, "b": 20, "c": 10},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diags := res.ReadContext(context.Background(), d, nil); diags.HasError() {
+				t.Fatal(diags)
+			}
+			if tt.gone {
+				if d.Id() != "" {
+					t.Errorf("ID = %q, want the resource gone", d.Id())
+				}
+				return
+			}
+			items, drift := shardsOf(d, "rule_ids")
+			if !reflect.DeepEqual(items, tt.wantItems) {
+				t.Errorf("shard rules = %v, want %v", items, tt.wantItems)
+			}
+			if !reflect.DeepEqual(drift, tt.wantDrift) {
+				t.Errorf("drifted shards = %v, want %v", drift, tt.wantDrift)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/shard"
+)
+
+// shardSchema is the schema of the shards of a sharded resource, computed at plan time so that
+// previews show which shards change. itemsField names the items of each shard.
+func shardSchema(itemsField, description string) *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Description: "Shards, in order",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"id": {
+					Type:        schema.TypeInt,
+					Computed:    true,
+					Description: "Number of the shard, in the ID of its NSX object",
+				},
+				itemsField: {
+					Type:        schema.TypeList,
+					Computed:    true,
+					Elem:        &schema.Schema{Type: schema.TypeString},
+					Description: description,
+				},
+				"drifted": {
+					Type:        schema.TypeBool,
+					Computed:    true,
+					Description: "Whether the NSX object of the shard was changed or deleted outside of the resource",
+				},
+			},
+		},
+	}
+}
+
+func expandShards(v interface{}, itemsField string) []shard.Shard {
+	list, _ := v.([]interface{})
+	shards := make([]shard.Shard, 0, len(list))
+	for _, item := range list {
+		m, _ := item.(map[string]interface{})
+		if m == nil {
+			continue
+		}
+		s := shard.Shard{ID: m["id"].(int)}
+		for _, i := range m[itemsField].([]interface{}) {
+			s.Items = append(s.Items, i.(string))
+		}
+		shards = append(shards, s)
+	}
+	return shards
+}
+
+// driftedShards returns the IDs of the shards read as drifted.
+func driftedShards(v interface{}) map[int]bool {
+	list, _ := v.([]interface{})
+	drifted := map[int]bool{}
+	for _, item := range list {
+		if m, _ := item.(map[string]interface{}); m != nil && m["drifted"] == true {
+			drifted[m["id"].(int)] = true
+		}
+	}
+	return drifted
+}
+
+func flattenShards(shards []shard.Shard, itemsField string, drifted map[int]bool) []interface{} {
+	list := make([]interface{}, 0, len(shards))
+	for _, s := range shards {
+		list = append(list, map[string]interface{}{"id": s.ID, itemsField: s.Items, "drifted": drifted[s.ID]})
+	}
+	return list
+}
+
+// planShards sets the shards planned by plan from the previous ones, or marks them unknown when
+// the items aren't known yet. Drifted shards are planned again so that the update rewrites them.
+func planShards(d *schema.ResourceDiff, itemsSource, itemsField string,
+	plan func(previous []shard.Shard) ([]shard.Shard, error)) error {
+	if !d.NewValueKnown(itemsSource) {
+		return d.SetNewComputed("shard")
+	}
+	old, _ := d.GetChange("shard")
+	previous := expandShards(old, itemsField)
+	next, err := plan(previous)
+	if err != nil {
+		return err
+	}
+	if d.Id() != "" && fmt.Sprint(previous) == fmt.Sprint(next) && len(driftedShards(old)) == 0 {
+		return nil
+	}
+	return d.SetNew("shard", flattenShards(next, itemsField, nil))
+}
+
+// shardID returns the NSX ID of a shard of the object nsxID.
+func shardID(nsxID string, id int) string {
+	return fmt.Sprintf("%s-shard-%d", nsxID, id)
+}
+
+// stringSet returns the values of a set of strings, sorted.
+func stringSet(v interface{}) []string {
+	set, _ := v.(*schema.Set)
+	if set == nil {
+		return nil
+	}
+	values := make([]string, 0, set.Len())
+	for _, item := range set.List() {
+		values = append(values, item.(string))
+	}
+	sort.Strings(values)
+	return values
+}
+
+// drifted tells whether obj differs from want on the fields of want but its ID, type and children.
+func drifted(want, obj map[string]interface{}) bool {
+	for k, v := range want {
+		switch k {
+		case "id", "resource_type", "children", "rules":
+			continue
+		}
+		if canonical(v) != canonical(obj[k]) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonical returns a JSON value in a form that doesn't depend on what NSX leaves out or
+// reorders: missing fields, zero values and empty lists are the same, and lists are compared
+// regardless of order.
+func canonical(v interface{}) string {
+	b, _ := json.Marshal(v)
+	var value interface{}
+	_ = json.Unmarshal(b, &value)
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		if !value {
+			return ""
+		}
+	case string:
+		if value == "" {
+			return ""
+		}
+	case float64:
+		if value == 0 {
+			return ""
+		}
+	case []interface{}:
+		if len(value) == 0 {
+			return ""
+		}
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, canonical(item))
+		}
+		sort.Strings(items)
+		return "[" + strings.Join(items, ",") + "]"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k, item := range value {
+			if canonical(item) != "" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		fields := make([]string, 0, len(keys))
+		for _, k := range keys {
+			fields = append(fields, strconv.Quote(k)+":"+canonical(value[k]))
+		}
+		return "{" + strings.Join(fields, ",") + "}"
+	}
+	return string(b)
+}
+
+// orAny returns values, or ANY when there are none, as NSX expects for rule fields.
+func orAny(values []string) []string {
+	if len(values) == 0 {
+		return []string{"ANY"}
+	}
+	return values
+}
@@ -0,0 +1,124 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// tag is an NSX tag. Empty scopes are legal.
+type tag struct {
+	Scope string
+	Tag   string
+}
+
+// tagFilterSchema is the schema of the tags objects must all carry to be returned, shaped like the
+// tags of the upstream resources.
+func tagFilterSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		Description: "Tags the objects must all carry",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"scope": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "Scope of the tag, any scope when empty",
+				},
+				"tag": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "Value of the tag, any value when empty",
+				},
+			},
+		},
+	}
+}
+
+// tagInputSchema is the schema of the tags of a managed object.
+func tagInputSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		Description: "Tags of the object",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"scope": {Type: schema.TypeString, Optional: true},
+				"tag":   {Type: schema.TypeString, Optional: true},
+			},
+		},
+	}
+}
+
+// tagSchema is the schema of the tags of a returned object.
+func tagSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Description: "Tags of the object",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"scope": {Type: schema.TypeString, Computed: true},
+				"tag":   {Type: schema.TypeString, Computed: true},
+			},
+		},
+	}
+}
+
+// expandTags reads a list of tags from the configuration.
+func expandTags(list []interface{}) []tag {
+	tags := make([]tag, 0, len(list))
+	for _, item := range list {
+		m, _ := item.(map[string]interface{})
+		scope, _ := m["scope"].(string)
+		value, _ := m["tag"].(string)
+		tags = append(tags, tag{Scope: scope, Tag: value})
+	}
+	return tags
+}
+
+// tagsOf returns the tags of an NSX object.
+func tagsOf(obj map[string]interface{}) []tag {
+	list, _ := obj["tags"].([]interface{})
+	return expandTags(list)
+}
+
+// flattenTags returns tags in the shape of tagSchema.
+func flattenTags(tags []tag) []interface{} {
+	list := make([]interface{}, 0, len(tags))
+	for _, t := range tags {
+		list = append(list, map[string]interface{}{"scope": t.Scope, "tag": t.Tag})
+	}
+	return list
+}
+
+// hasTags tells whether obj carries all the wanted tags. An empty scope or value matches any.
+func hasTags(obj map[string]interface{}, wanted []tag) bool {
+	tags := tagsOf(obj)
+	for _, w := range wanted {
+		found := false
+		for _, t := range tags {
+			if (w.Scope == "" || w.Scope == t.Scope) && (w.Tag == "" || w.Tag == t.Tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/redact"
+)
+
+// requestIDHeader is the header NSX identifies each API request with.
+const requestIDHeader = "X-Nsx-Requestid"
+
+// AuditRecord is one line of the audit log.
+type AuditRecord struct {
+	Time      time.Time       `json:"time"`
+	Stack     string          `json:"stack,omitempty"`
+	URN       string          `json:"urn,omitempty"`
+	Type      string          `json:"type,omitempty"`
+	Method    string          `json:"method"`
+	Path      string          `json:"path"`
+	Status    int             `json:"status,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	RequestID string          `json:"requestId,omitempty"`
+	Body      json.RawMessage `json:"body,omitempty"`
+}
+
+// AuditLog appends a JSON line per mutating request to a file. Each line is synced to disk as soon as
+// it is written.
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+	ops  *Operations
+}
+
+// OpenAuditLog opens, or creates, the audit log at path. ops attributes requests to resources.
+func OpenAuditLog(path string, ops *Operations) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open audit log: %w", err)
+	}
+	return &AuditLog{file: f, ops: ops}, nil
+}
+
+// Middleware records the mutating requests going through it, whether they succeed or not.
+func (a *AuditLog) Middleware(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if !IsMutating(req) {
+			return next.RoundTrip(req)
+		}
+		body, err := bufferBody(req)
+		if err != nil {
+			return nil, err
+		}
+
+		record := AuditRecord{
+			Time:   time.Now().UTC(),
+			Method: req.Method,
+			Path:   policyPath(req.URL.Path),
+			Body:   redactedBody(body),
+		}
+		if op := a.ops.Of(req); op != nil {
+			record.URN = op.URN
+			record.Type = op.Type
+			record.Stack = stackOf(op.URN)
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			record.Error = err.Error()
+		} else {
+			record.Status = resp.StatusCode
+			record.RequestID = resp.Header.Get(requestIDHeader)
+		}
+		a.write(record)
+		return resp, err
+	})
+}
+
+// write appends record as a single write, so that lines from concurrent operations (and from other
+// provider processes sharing the file) never interleave.
+func (a *AuditLog) write(record AuditRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("[WARN] cannot encode audit record: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.file.Write(line); err != nil {
+		log.Printf("[WARN] cannot write audit log: %v", err)
+		return
+	}
+	if err := a.file.Sync(); err != nil {
+		log.Printf("[WARN] cannot sync audit log: %v", err)
+	}
+}
+
+// Close closes the file of the audit log.
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
+
+// bufferBody reads the body of req and replaces it with a replayable copy.
+func bufferBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+func redactedBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if redacted, ok := redact.JSON(body); ok {
+		return redacted
+	}
+	omitted, _ := json.Marshal(fmt.Sprintf("<%d bytes of non-JSON content omitted>", len(body)))
+	return omitted
+}
+
+// policyPath strips the API prefix from path, leaving the policy path of the object (for example
+// /infra/domains/default/groups/web) for Policy API calls.
+func policyPath(path string) string {
+	if i := strings.Index(path, "/policy/api/v1"); i >= 0 {
+		return path[i+len("/policy/api/v1"):]
+	}
+	if i := strings.Index(path, "/api/v1"); i >= 0 {
+		return path[i:]
+	}
+	return path
+}
+
+// stackOf extracts the stack name from a URN (urn:pulumi:stack::project::type::name).
+func stackOf(urn string) string {
+	parts := strings.SplitN(strings.TrimPrefix(urn, "urn:pulumi:"), "::", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0]
+}
@@ -0,0 +1,143 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Deployment types of a manager.
+const (
+	LocalManager  = "LocalManager"
+	GlobalManager = "GlobalManager"
+	VMC           = "VMC"
+)
+
+// Capabilities describe what the manager supports.
+type Capabilities struct {
+	Version           string
+	Build             string
+	DeploymentType    string
+	LicensedFeatures  []string
+	EnforcementPoints []string // paths
+	PolicyAPI         bool
+	ManagerAPI        bool
+	Projects          bool
+	EVPN              bool
+	IDS               bool
+}
+
+// The versions introducing projects, EVPN and IDS/IPS.
+var (
+	minProjectsVersion = Version{4, 0, 0}
+	minEVPNVersion     = Version{3, 1, 0}
+	minIDSVersion      = Version{3, 1, 0}
+)
+
+// Capabilities returns the capabilities of the manager, computed from its API. vmc tells whether
+// the provider is configured for VMC, where the Manager API is never available. The version is
+// left empty, and the capabilities depending on it false, when the manager serves no version API.
+func (c *Client) Capabilities(ctx context.Context, vmc bool) (*Capabilities, error) {
+	caps := &Capabilities{DeploymentType: LocalManager}
+	var version Version
+	nodeVersion, err := c.NodeVersion(ctx)
+	switch {
+	case err == nil:
+		if version, err = nodeVersion.Version(); err != nil {
+			return nil, err
+		}
+		caps.Version = version.String()
+		if len(version) > 3 {
+			caps.Build = version[len(version)-1:].String()
+		}
+	case !isUnavailable(err):
+		return nil, err
+	}
+
+	if vmc {
+		caps.DeploymentType = VMC
+	} else if gm, err := c.available(ctx, "/global-manager/api/v1/global-infra"); err != nil {
+		return nil, err
+	} else if gm {
+		caps.DeploymentType = GlobalManager
+	}
+	if caps.PolicyAPI, err = c.available(ctx, "/policy/api/v1/infra"); err != nil {
+		return nil, err
+	}
+	if !vmc {
+		if caps.ManagerAPI, err = c.available(ctx, "/api/v1/node"); err != nil {
+			return nil, err
+		}
+	}
+
+	if caps.ManagerAPI {
+		var features struct {
+			FeaturesAllowed []string `json:"features_allowed"`
+		}
+		if err := c.Do(ctx, "GET", "/api/v1/licenses/licensed-features", nil, &features); err != nil {
+			return nil, fmt.Errorf("cannot read the licensed features: %w", err)
+		}
+		caps.LicensedFeatures = features.FeaturesAllowed
+		sort.Strings(caps.LicensedFeatures)
+	}
+
+	local := caps.DeploymentType != GlobalManager
+	infra := "/policy/api/v1/infra"
+	if !local {
+		infra = "/global-manager/api/v1/global-infra"
+	}
+	if caps.PolicyAPI || !local {
+		var points struct {
+			Results []struct {
+				Path string `json:"path"`
+			} `json:"results"`
+		}
+		if err := c.Do(ctx, "GET", infra+"/sites/default/enforcement-points", nil, &points); err != nil {
+			return nil, fmt.Errorf("cannot read the enforcement points: %w", err)
+		}
+		for _, p := range points.Results {
+			caps.EnforcementPoints = append(caps.EnforcementPoints, p.Path)
+		}
+	}
+
+	caps.Projects = local && version != nil && version.AtLeast(minProjectsVersion)
+	caps.EVPN = local && version != nil && version.AtLeast(minEVPNVersion)
+	caps.IDS = version != nil && version.AtLeast(minIDSVersion) && hasFeature(caps.LicensedFeatures, "IDS", "IDPS")
+	return caps, nil
+}
+
+// available tells whether the manager serves the API at path.
+func (c *Client) available(ctx context.Context, path string) (bool, error) {
+	err := c.Do(ctx, "GET", path, nil, nil)
+	if isUnavailable(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// hasFeature tells whether one of the licensed features contains one of names.
+func hasFeature(features []string, names ...string) bool {
+	for _, f := range features {
+		for _, name := range names {
+			if strings.Contains(strings.ToUpper(f), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
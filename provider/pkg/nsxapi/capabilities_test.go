@@ -0,0 +1,124 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	points := `{"results":[{"path":"/infra/sites/default/enforcement-points/default"}]}`
+	tests := []struct {
+		name    string
+		vmc     bool
+		served  map[string]string // path: body, "500" for a server error
+		want    *Capabilities
+		wantErr string
+	}{
+		{
+			name: "local manager",
+			served: map[string]string{
+				"/api/v1/node/version":                                  `{"product_version":"4.1.2.0.0.22589037"}`,
+				"/policy/api/v1/infra":                                  `{}`,
+				"/api/v1/node":                                          `{}`,
+				"/api/v1/licenses/licensed-features":                    `{"features_allowed":["IDPS","DFW"]}`,
+				"/policy/api/v1/infra/sites/default/enforcement-points": points,
+			},
+			want: &Capabilities{
+				Version:           "4.1.2.0.0.22589037",
+				Build:             "22589037",
+				DeploymentType:    LocalManager,
+				LicensedFeatures:  []string{"DFW", "IDPS"},
+				EnforcementPoints: []string{"/infra/sites/default/enforcement-points/default"},
+				PolicyAPI:         true,
+				ManagerAPI:        true,
+				Projects:          true,
+				EVPN:              true,
+				IDS:               true,
+			},
+		},
+		{
+			name: "VMC without version API",
+			vmc:  true,
+			served: map[string]string{
+				"/policy/api/v1/infra": `{}`,
+				"/policy/api/v1/infra/sites/default/enforcement-points": points,
+			},
+			want: &Capabilities{
+				DeploymentType:    VMC,
+				EnforcementPoints: []string{"/infra/sites/default/enforcement-points/default"},
+				PolicyAPI:         true,
+			},
+		},
+		{
+			name: "licensed features failing",
+			served: map[string]string{
+				"/api/v1/node/version":               `{"product_version":"4.1.2"}`,
+				"/policy/api/v1/infra":               `{}`,
+				"/api/v1/node":                       `{}`,
+				"/api/v1/licenses/licensed-features": "500",
+			},
+			wantErr: "licensed features",
+		},
+		{
+			name: "enforcement points failing",
+			served: map[string]string{
+				"/policy/api/v1/node/version":                           `{"product_version":"4.1.2"}`,
+				"/policy/api/v1/infra":                                  `{}`,
+				"/policy/api/v1/infra/sites/default/enforcement-points": "500",
+			},
+			vmc:     true,
+			wantErr: "enforcement points",
+		},
+		{
+			name:    "version failing",
+			served:  map[string]string{"/policy/api/v1/node/version": "500"},
+			wantErr: "version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+				body, ok := tt.served[r.URL.Path]
+				switch {
+				case !ok:
+					w.WriteHeader(http.StatusNotFound)
+				case body == "500":
+					w.WriteHeader(http.StatusInternalServerError)
+				default:
+					_, _ = io.WriteString(w, body)
+				}
+			})
+			caps, err := c.Capabilities(context.Background(), tt.vmc)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Capabilities() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(caps, tt.want) {
+				t.Errorf("Capabilities() = %+v, want %+v", caps, tt.want)
+			}
+		})
+	}
+}
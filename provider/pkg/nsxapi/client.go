@@ -0,0 +1,190 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client calls the NSX API for the features this provider implements itself. Its requests go
+// through the same middlewares as those of the upstream provider.
+type Client struct {
+	// GlobalManager selects the Global Manager flavor of the Policy API.
+	GlobalManager bool
+
+	base   *url.URL
+	client *http.Client
+	auth   Auth
+}
+
+// Auth authenticates a request to the NSX API.
+type Auth func(req *http.Request) error
+
+// NewClient returns a client of the manager at base, sending requests through transport.
+func NewClient(base *url.URL, transport http.RoundTripper, auth Auth) *Client {
+	return &Client{base: base, client: &http.Client{Transport: transport}, auth: auth}
+}
+
+// BasicAuth authenticates with a username and password. remote selects vIDM/LDAP users
+// (remoteAuth).
+func BasicAuth(username, password string, remote bool) Auth {
+	scheme := "Basic"
+	if remote {
+		scheme = "Remote"
+	}
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return func(req *http.Request) error {
+		req.Header.Set("Authorization", scheme+" "+credentials)
+		return nil
+	}
+}
+
+// VMCAuth authenticates with an access token obtained from the VMC auth host for an API token,
+// renewed before it expires. The exchange goes through rt, or http.DefaultTransport if nil.
+func VMCAuth(authURL, apiToken string, rt http.RoundTripper) Auth {
+	var mu sync.Mutex
+	var accessToken string
+	var expires time.Time
+	return func(req *http.Request) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if accessToken == "" || time.Now().After(expires) {
+			token, lifetime, err := exchangeVMCToken(req.Context(), authURL, apiToken, rt)
+			if err != nil {
+				return err
+			}
+			// Renew a minute early, a request may take that long.
+			accessToken, expires = token, time.Now().Add(lifetime-time.Minute)
+		}
+		req.Header.Set("csp-auth-token", accessToken)
+		return nil
+	}
+}
+
+func exchangeVMCToken(ctx context.Context, authURL, apiToken string,
+	rt http.RoundTripper) (string, time.Duration, error) {
+	form := url.Values{"refresh_token": {apiToken}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, authURL, strings.NewReader(form))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("VMC token exchange failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("VMC token exchange failed with %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", 0, fmt.Errorf("VMC token exchange failed: %w", err)
+	}
+	return token.AccessToken, time.Duration(token.ExpiresIn) * time.Second, nil
+}
+
+// PolicyAPI returns the API path of a Policy object, such as /infra/domains/default/groups/web.
+func (c *Client) PolicyAPI(path string) string {
+	if c.GlobalManager && strings.HasPrefix(path, "/infra") {
+		return "/global-manager/api/v1/global-infra" + strings.TrimPrefix(path, "/infra")
+	}
+	if c.GlobalManager {
+		return "/global-manager/api/v1" + path
+	}
+	return "/policy/api/v1" + path
+}
+
+// APIError is an error returned by the NSX API.
+type APIError struct {
+	StatusCode int
+	ErrorCode  int    `json:"error_code"`
+	Message    string `json:"error_message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("NSX API error %d", e.StatusCode)
+	}
+	return fmt.Sprintf("NSX API error %d (code %d): %s", e.StatusCode, e.ErrorCode, e.Message)
+}
+
+// IsNotFound tells whether err is an NSX API 404.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Do sends a request to path, relative to the manager URL and with its query if any. in, when not
+// nil, is sent as JSON, and the JSON response is decoded into out when not nil.
+func (c *Client) Do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base.String()+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.auth != nil {
+		if err := c.auth(req); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(payload, apiErr)
+		return apiErr
+	}
+	if out == nil || len(payload) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(payload, out); err != nil {
+		return fmt.Errorf("cannot decode the response to %s %s: %w", method, path, err)
+	}
+	return nil
+}
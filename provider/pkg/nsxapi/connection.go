@@ -0,0 +1,204 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nsxapi holds the state this provider keeps about its NSX manager, next to the
+// connection the upstream Terraform provider manages on its own.
+package nsxapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/redact"
+)
+
+// Connection tracks the link between one provider instance and its NSX manager. It is created
+// before the provider is configured and filled in by the configure step.
+type Connection struct {
+	mu          sync.RWMutex
+	unreachable error
+	readOnly    bool
+	dfwSnapshot bool
+	operations  *Operations
+	redactor    *redact.Redactor
+	credentials map[string]string
+	client      *Client
+	auditLog    *AuditLog
+
+	versionMu sync.Mutex
+	version   Version
+
+	vmc          bool
+	capsMu       sync.Mutex
+	capabilities *Capabilities
+}
+
+// NewConnection returns an unconfigured Connection.
+func NewConnection() *Connection {
+	return &Connection{operations: NewOperations(), redactor: redact.NewRedactor()}
+}
+
+// Operations returns the resource operations in progress on this provider instance.
+func (c *Connection) Operations() *Operations {
+	return c.operations
+}
+
+// Redactor returns the redactor the secrets of this provider instance are registered with.
+func (c *Connection) Redactor() *redact.Redactor {
+	return c.redactor
+}
+
+// SetCredentials records the credentials resolved from files or commands, keyed by the upstream
+// setting they are for.
+func (c *Connection) SetCredentials(credentials map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.credentials = credentials
+}
+
+// Credentials returns the credentials recorded by SetCredentials.
+func (c *Connection) Credentials() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.credentials
+}
+
+// SetClient records the client of the manager, once the provider is configured.
+func (c *Connection) SetClient(client *Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.client = client
+}
+
+// SetAuditLog records the audit log of the provider, closing the one recorded before, if any.
+func (c *Connection) SetAuditLog(auditLog *AuditLog) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous := c.auditLog
+	c.auditLog = auditLog
+	if previous != nil {
+		return previous.Close()
+	}
+	return nil
+}
+
+// Close releases the resources held by the connection once the provider is shut down.
+func (c *Connection) Close() error {
+	return c.SetAuditLog(nil)
+}
+
+// Client returns the client of the manager, or nil when the provider isn't configured, or the
+// manager is unreachable.
+func (c *Connection) Client() *Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.client
+}
+
+// Version returns the version of the manager. It is fetched once per provider instance, failures
+// are retried on the next call.
+func (c *Connection) Version(ctx context.Context) (Version, error) {
+	client := c.Client()
+	if client == nil {
+		return nil, fmt.Errorf("the provider is not connected to an NSX manager")
+	}
+	c.versionMu.Lock()
+	defer c.versionMu.Unlock()
+	if c.version == nil {
+		nodeVersion, err := client.NodeVersion(ctx)
+		if err != nil {
+			return nil, err
+		}
+		version, err := nodeVersion.Version()
+		if err != nil {
+			return nil, err
+		}
+		c.version = version
+	}
+	return c.version, nil
+}
+
+// SetVMC records whether the provider is configured for VMC.
+func (c *Connection) SetVMC(vmc bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.vmc = vmc
+}
+
+// Capabilities returns the capabilities of the manager. They are computed once per provider
+// instance, failures are retried on the next call.
+func (c *Connection) Capabilities(ctx context.Context) (*Capabilities, error) {
+	client := c.Client()
+	if client == nil {
+		return nil, fmt.Errorf("the provider is not connected to an NSX manager")
+	}
+	c.capsMu.Lock()
+	defer c.capsMu.Unlock()
+	if c.capabilities == nil {
+		c.mu.RLock()
+		vmc := c.vmc
+		c.mu.RUnlock()
+		caps, err := client.Capabilities(ctx, vmc)
+		if err != nil {
+			return nil, err
+		}
+		c.capabilities = caps
+	}
+	return c.capabilities, nil
+}
+
+// SetUnreachable records that the manager could not be reached while configuring the provider.
+func (c *Connection) SetUnreachable(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unreachable = err
+}
+
+// Unreachable returns the reason the manager was found unreachable, or nil if it was reached or
+// never probed.
+func (c *Connection) Unreachable() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.unreachable
+}
+
+// SetReadOnly records whether the provider may change NSX.
+func (c *Connection) SetReadOnly(readOnly bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readOnly = readOnly
+}
+
+// ReadOnly tells whether the provider was configured with readOnly.
+func (c *Connection) ReadOnly() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.readOnly
+}
+
+// SetDFWSnapshot records whether the distributed firewall is saved to a draft before it is first
+// changed, and restored from it on failure.
+func (c *Connection) SetDFWSnapshot(snapshot bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dfwSnapshot = snapshot
+}
+
+// DFWSnapshot tells whether the provider was configured with dfwSnapshot.
+func (c *Connection) DFWSnapshot() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.dfwSnapshot
+}
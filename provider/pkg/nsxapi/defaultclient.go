@@ -0,0 +1,78 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// hostRoutes holds the transports http.DefaultClient uses for specific hosts.
+var hostRoutes = struct {
+	sync.Mutex
+	fallback http.RoundTripper
+	routes   map[string]*hostRoute
+}{routes: map[string]*hostRoute{}}
+
+type hostRoute struct {
+	rt http.RoundTripper
+}
+
+// RouteHost makes http.DefaultClient send the requests for host (a host name, with or without a
+// port) through rt, until the returned function is called. The upstream provider exchanges VMC API
+// tokens with http.DefaultClient while it is configured: routing the auth host for the duration of
+// that call is the only way to apply the connection settings of the provider to it. The clients
+// the provider builds itself are given rt instead.
+func RouteHost(host string, rt http.RoundTripper) (unroute func()) {
+	host = strings.ToLower(host)
+	hostRoutes.Lock()
+	defer hostRoutes.Unlock()
+	if len(hostRoutes.routes) == 0 {
+		hostRoutes.fallback = http.DefaultClient.Transport
+		http.DefaultClient.Transport = roundTripperFunc(routeRequest)
+	}
+	route := &hostRoute{rt: rt}
+	hostRoutes.routes[host] = route
+	return func() {
+		hostRoutes.Lock()
+		defer hostRoutes.Unlock()
+		if hostRoutes.routes[host] != route {
+			return // routed again since
+		}
+		delete(hostRoutes.routes, host)
+		if len(hostRoutes.routes) == 0 {
+			http.DefaultClient.Transport = hostRoutes.fallback
+		}
+	}
+}
+
+// routeRequest sends req through the transport routed for its host, if any.
+func routeRequest(req *http.Request) (*http.Response, error) {
+	hostRoutes.Lock()
+	route, ok := hostRoutes.routes[strings.ToLower(req.URL.Host)]
+	if !ok {
+		route, ok = hostRoutes.routes[strings.ToLower(req.URL.Hostname())]
+	}
+	rt := hostRoutes.fallback
+	if ok {
+		rt = route.rt
+	}
+	hostRoutes.Unlock()
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return rt.RoundTrip(req)
+}
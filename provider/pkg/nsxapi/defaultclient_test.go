@@ -0,0 +1,45 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRouteHost(t *testing.T) {
+	original := http.DefaultClient.Transport
+	routed := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("routed"))}, nil
+	})
+
+	unroute := RouteHost("Console.Example.com", routed)
+	resp, err := http.DefaultClient.Get("https://console.example.com/csp/gateway/am/api/auth/api-tokens/authorize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "routed" {
+		t.Errorf("got %q, want the routed transport", body)
+	}
+
+	unroute()
+	if http.DefaultClient.Transport != original {
+		t.Errorf("http.DefaultClient.Transport not restored")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// SaveDraft saves the current distributed firewall configuration as a new manual draft with the
+// given ID. NSX takes the configuration of the moment when it creates a manual draft; patching an
+// existing draft would keep its configuration, so an existing ID is an error.
+func (c *Client) SaveDraft(ctx context.Context, id, description string) error {
+	path := c.PolicyAPI("/infra/drafts/" + url.PathEscape(id))
+	err := c.Do(ctx, "GET", path, nil, nil)
+	if err == nil {
+		return fmt.Errorf("draft %s already exists", id)
+	}
+	if !IsNotFound(err) {
+		return err
+	}
+	draft := map[string]interface{}{
+		"display_name": id,
+		"description":  description,
+	}
+	return c.Do(ctx, "PATCH", path, draft, nil)
+}
+
+// PublishDraft makes the distributed firewall configuration that of the draft with the given ID.
+func (c *Client) PublishDraft(ctx context.Context, id string) error {
+	path := c.PolicyAPI("/infra/drafts/"+url.PathEscape(id)) + "?action=publish"
+	return c.Do(ctx, "POST", path, map[string]interface{}{"resource_type": "Infra"}, nil)
+}
+
+// ListDrafts returns the IDs of the distributed firewall drafts, manual and automatic.
+func (c *Client) ListDrafts(ctx context.Context) ([]string, error) {
+	drafts, err := c.List(ctx, c.PolicyAPI("/infra/drafts"))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(drafts))
+	for _, d := range drafts {
+		if id, ok := d["id"].(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// DeleteDraft deletes the draft with the given ID. A draft that is already gone is not an error.
+func (c *Client) DeleteDraft(ctx context.Context, id string) error {
+	err := c.Do(ctx, "DELETE", c.PolicyAPI("/infra/drafts/"+url.PathEscape(id)), nil, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
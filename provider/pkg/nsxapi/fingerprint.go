@@ -0,0 +1,111 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ParseFingerprints parses a comma-separated list of SHA-256 certificate fingerprints, written in
+// hexadecimal with or without colons (as printed by `openssl x509 -fingerprint -sha256`).
+func ParseFingerprints(s string) ([][]byte, error) {
+	var fingerprints [][]byte
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		fingerprint, err := hex.DecodeString(strings.ReplaceAll(f, ":", ""))
+		if err != nil || len(fingerprint) != sha256.Size {
+			return nil, fmt.Errorf("invalid SHA-256 fingerprint %q", f)
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	return fingerprints, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a DER certificate, as colon-separated hex.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// verifyFingerprints accepts a TLS connection when the leaf certificate the server presents has one
+// of the given fingerprints, or is issued for the server by a CA certificate it sends with it that
+// has one of them. serverName is the host name or IP address the server is reached at.
+func verifyFingerprints(fingerprints [][]byte, serverName string) func(tls.ConnectionState) error {
+	pinned := func(cert *x509.Certificate) bool {
+		sum := sha256.Sum256(cert.Raw)
+		for _, fingerprint := range fingerprints {
+			if bytes.Equal(sum[:], fingerprint) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(cs tls.ConnectionState) error {
+		if serverName == "" {
+			serverName = cs.ServerName // not set for IP addresses
+		}
+		server := serverName
+		if server == "" {
+			server = "the server"
+		}
+		if len(cs.PeerCertificates) == 0 {
+			return fmt.Errorf("%s presented no certificate", server)
+		}
+		leaf, chain := cs.PeerCertificates[0], cs.PeerCertificates[1:]
+		if pinned(leaf) {
+			return nil
+		}
+		for i, ca := range chain {
+			if !pinned(ca) {
+				continue
+			}
+			// Anyone can send the pinned CA along with their own certificate: the leaf must chain
+			// up to it, and be issued for the server.
+			roots := x509.NewCertPool()
+			roots.AddCert(ca)
+			intermediates := x509.NewCertPool()
+			for j, cert := range chain {
+				if j != i {
+					intermediates.AddCert(cert)
+				}
+			}
+			_, err := leaf.Verify(x509.VerifyOptions{
+				DNSName:       serverName,
+				Roots:         roots,
+				Intermediates: intermediates,
+			})
+			if err != nil {
+				return fmt.Errorf("certificate of %s (%s) is not valid under the pinned CA %s: %w",
+					server, leaf.Subject, ca.Subject, err)
+			}
+			return nil
+		}
+		return fmt.Errorf("certificate fingerprint mismatch: %s presented %s (%s), which matches no "+
+			"caFingerprint", server, Fingerprint(leaf.Raw), leaf.Subject)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate with its key, issued by parent or self-signed.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, name string, ca bool, parent *testCert, ips ...net.IP) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  ca,
+		BasicConstraintsValid: true,
+		IPAddresses:           ips,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+func fingerprintOf(c *testCert) []byte {
+	sum := sha256.Sum256(c.cert.Raw)
+	return sum[:]
+}
+
+func TestFingerprintPinning(t *testing.T) {
+	localhost := net.IPv4(127, 0, 0, 1)
+	ca := newTestCert(t, "NSX CA", true, nil)
+	issued := newTestCert(t, "nsx-manager", false, ca, localhost)
+	otherHost := newTestCert(t, "other-manager", false, ca, net.IPv4(192, 0, 2, 1))
+	selfSigned := newTestCert(t, "nsx-manager", false, nil, localhost)
+
+	tests := []struct {
+		name    string
+		chain   []*testCert
+		pin     *testCert
+		wantErr bool
+	}{
+		{"leaf pinned", []*testCert{selfSigned}, selfSigned, false},
+		{"issued leaf pinned", []*testCert{issued, ca}, issued, false},
+		{"CA pinned", []*testCert{issued, ca}, ca, false},
+		{"CA pinned, other host", []*testCert{otherHost, ca}, ca, true},
+		{"CA appended to a foreign leaf", []*testCert{selfSigned, ca}, ca, true},
+		{"CA pinned, not sent", []*testCert{issued}, ca, true},
+		{"no match", []*testCert{selfSigned}, issued, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := tls.Certificate{PrivateKey: tt.chain[0].key, Leaf: tt.chain[0].cert}
+			for _, c := range tt.chain {
+				cert.Certificate = append(cert.Certificate, c.cert.Raw)
+			}
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+			srv.Config.ErrorLog = log.New(io.Discard, "", 0) // refused handshakes
+			srv.StartTLS()
+			defer srv.Close()
+
+			transport, err := NewTransport(TransportOptions{
+				Fingerprints: [][]byte{fingerprintOf(tt.pin)},
+				ServerName:   "127.0.0.1",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := (&http.Client{Transport: transport}).Get(srv.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GET error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// Gateway is a local HTTPS endpoint that forwards requests to the manager through a transport
+// of ours. The upstream provider builds its HTTP clients privately, pointing its `host` at a
+// gateway is how requests get checked and recorded on their way to NSX.
+type Gateway struct {
+	target   *url.URL
+	listener net.Listener
+	server   *http.Server
+	certPEM  []byte
+}
+
+// StartGateway serves a gateway to target on a loopback port.
+func StartGateway(target *url.URL, transport http.RoundTripper) (*Gateway, error) {
+	cert, certPEM, err := selfSignedCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create gateway certificate: %w", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("cannot start gateway: %w", err)
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			// Paths already carry the target path, see Host.
+			r.Out.URL.Scheme = target.Scheme
+			r.Out.URL.Host = target.Host
+			r.Out.Host = target.Host
+		},
+		Transport: transport,
+		ErrorLog:  log.Default(),
+	}
+	g := &Gateway{
+		target:   target,
+		listener: listener,
+		certPEM:  certPEM,
+		server: &http.Server{
+			Handler:           proxy,
+			ReadHeaderTimeout: 30 * time.Second,
+			TLSConfig: &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{cert},
+			},
+		},
+	}
+	go func() {
+		if err := g.server.ServeTLS(listener, "", ""); err != nil && err != http.ErrServerClosed {
+			log.Printf("[ERROR] NSX gateway stopped: %v", err)
+		}
+	}()
+	return g, nil
+}
+
+// Host returns the value to use as the upstream provider `host` to go through the gateway.
+func (g *Gateway) Host() string {
+	return "https://" + g.listener.Addr().String() + g.target.Path
+}
+
+// CertificatePEM returns the certificate the gateway serves, for the upstream provider to trust.
+func (g *Gateway) CertificatePEM() []byte {
+	return g.certPEM
+}
+
+// Close stops the gateway.
+func (g *Gateway) Close() error {
+	return g.server.Close()
+}
+
+// selfSignedCertificate creates a throwaway certificate for the loopback address.
+func selfSignedCertificate() (tls.Certificate, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "pulumi-nsxt gateway"},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(7 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	return cert, certPEM, err
+}
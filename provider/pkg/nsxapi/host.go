@@ -0,0 +1,103 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ParseHost turns the provider `host` setting into the URL of the NSX manager. Like the upstream
+// provider, it accepts a bare hostname, a host:port pair or a full https URL (VMC reverse proxies
+// carry a path).
+func ParseHost(host string) (*url.URL, error) {
+	if host == "" {
+		return nil, fmt.Errorf("host must be provided")
+	}
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host %q: %w", host, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid host %q: missing hostname", host)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return u, nil
+}
+
+// hostPort returns the address to dial for u, defaulting the port from the scheme.
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "http" {
+		return net.JoinHostPort(u.Hostname(), "80")
+	}
+	return net.JoinHostPort(u.Hostname(), "443")
+}
+
+// Probe checks that a TCP connection to the manager at u can be opened within timeout. Through a
+// proxy, it checks that the manager answers an HTTP request instead.
+func Probe(ctx context.Context, u *url.URL, timeout time.Duration, proxy *Proxy) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if proxy != nil {
+		proxyURL, err := proxy.URL(u)
+		if err != nil {
+			return err
+		}
+		if proxyURL != nil {
+			if err := probeThrough(ctx, u, proxy); err != nil {
+				return fmt.Errorf("NSX manager %s is unreachable through %s: %w", u.Host, proxyURL.Redacted(), err)
+			}
+			return nil
+		}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", hostPort(u))
+	if err != nil {
+		return fmt.Errorf("NSX manager %s is unreachable: %w", u.Host, err)
+	}
+	return conn.Close()
+}
+
+// probeThrough sends a request to u through proxy. Any answer will do, the certificate of the
+// manager isn't checked either.
+func probeThrough(ctx context.Context, u *url.URL, proxy *Proxy) error {
+	transport, err := NewTransport(TransportOptions{Insecure: true, Proxy: proxy})
+	if err != nil {
+		return err
+	}
+	defer transport.CloseIdleConnections()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String()+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
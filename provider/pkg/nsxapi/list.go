@@ -0,0 +1,77 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// pageSize is the number of objects requested per page. NSX caps it at 1000.
+const pageSize = 1000
+
+// List returns all the objects of a paged NSX API list, such as a Policy API collection or a search
+// query, following the cursor from page to page. path may carry a query.
+func (c *Client) List(ctx context.Context, path string) ([]map[string]interface{}, error) {
+	raws, err := c.ListRaw(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]map[string]interface{}, 0, len(raws))
+	for _, raw := range raws {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, fmt.Errorf("cannot decode the results of %s: %w", path, err)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// ListRaw is List for the lists whose results aren't objects, such as lists of IP addresses.
+func (c *Client) ListRaw(ctx context.Context, path string) ([]json.RawMessage, error) {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	var results []json.RawMessage
+	cursor := ""
+	for {
+		page := path + sep + "page_size=" + strconv.Itoa(pageSize)
+		if cursor != "" {
+			page += "&cursor=" + url.QueryEscape(cursor)
+		}
+		var result struct {
+			Results []json.RawMessage `json:"results"`
+			Cursor  string            `json:"cursor"`
+			Count   *int              `json:"result_count"`
+		}
+		if err := c.Do(ctx, "GET", page, nil, &result); err != nil {
+			return nil, err
+		}
+		results = append(results, result.Results...)
+		// The last page has no cursor on some versions, and the cursor of the end of the list on
+		// others.
+		done := result.Count != nil && len(results) >= *result.Count
+		if done || result.Cursor == "" || result.Cursor == cursor || len(result.Results) == 0 {
+			return results, nil
+		}
+		cursor = result.Cursor
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Operation is a Pulumi resource operation in progress.
+type Operation struct {
+	URN  string
+	Type string // resource type or function token
+	Kind string // create, read, update, delete or invoke
+	ID   string
+	// Context is the context of the operation, carrying its trace span.
+	Context context.Context
+}
+
+type operationKey struct{}
+
+// WithOperation returns a copy of ctx carrying op, so that the requests made with it are
+// attributed to op.
+func WithOperation(ctx context.Context, op *Operation) context.Context {
+	return context.WithValue(ctx, operationKey{}, op)
+}
+
+// OperationFrom returns the operation ctx carries, or nil.
+func OperationFrom(ctx context.Context) *Operation {
+	op, _ := ctx.Value(operationKey{}).(*Operation)
+	return op
+}
+
+// Operations attributes the NSX API requests of a provider instance to the resource operations
+// they are sent for, and sums up how much they were throttled.
+type Operations struct {
+	mu        sync.Mutex
+	throttled time.Duration
+	retries   int
+}
+
+// NewOperations returns an empty Operations.
+func NewOperations() *Operations {
+	return &Operations{}
+}
+
+// Throttling returns how long the requests waited for the NSX API limits so far, and how many of
+// them were retried because NSX asked to slow down.
+func (o *Operations) Throttling() (waited time.Duration, retries int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.throttled, o.retries
+}
+
+func (o *Operations) addThrottling(waited time.Duration, retries int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.throttled += waited
+	o.retries += retries
+}
+
+// Of returns the operation req is sent for, or nil. Only the requests made with the context of an
+// operation carry it: the resources and data sources the bridge runs get a context of its own, so
+// their requests aren't attributed.
+func (o *Operations) Of(req *http.Request) *Operation {
+	return OperationFrom(req.Context())
+}
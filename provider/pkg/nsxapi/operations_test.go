@@ -0,0 +1,44 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOperationsOf(t *testing.T) {
+	ops := NewOperations()
+	read := &Operation{Kind: "read", ID: "db"}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want *Operation
+	}{
+		{"context", WithOperation(context.Background(), read), read},
+		// Requests without the operation in their context aren't guessed from their path.
+		{"none", context.Background(), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PATCH", "/policy/api/v1/infra/segments/db", nil).WithContext(tt.ctx)
+			if got := ops.Of(req); got != tt.want {
+				t.Errorf("Of() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
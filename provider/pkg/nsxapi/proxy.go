@@ -0,0 +1,67 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Proxy sends connections through an HTTP, HTTPS or SOCKS5 proxy, except to the hosts excluded by
+// a NO_PROXY style list. Proxy credentials go in the user info of the proxy URL.
+type Proxy struct {
+	proxy func(*url.URL) (*url.URL, error)
+}
+
+// NewProxy returns the proxy at proxyURL, bypassed for the hosts in noProxy (comma-separated host
+// names, domain suffixes, IP addresses and CIDR ranges). It returns nil when proxyURL is empty.
+func NewProxy(proxyURL, noProxy string) (*Proxy, error) {
+	if proxyURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q: use http, https or socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing hostname", u.Redacted())
+	}
+	config := httpproxy.Config{
+		HTTPProxy:  proxyURL,
+		HTTPSProxy: proxyURL,
+		NoProxy:    noProxy,
+	}
+	return &Proxy{proxy: config.ProxyFunc()}, nil
+}
+
+// URL returns the URL of the proxy to use for target, or nil to connect directly.
+func (p *Proxy) URL(target *url.URL) (*url.URL, error) {
+	return p.proxy(target)
+}
+
+// apply makes transport use the proxy.
+func (p *Proxy) apply(transport *http.Transport) {
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return p.proxy(req.URL)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// readPosts are the POST endpoints that change nothing in NSX and stay reachable in read-only mode:
+// opening and closing sessions, and the aggregate search, which takes its query in the body. The
+// other searches and the realization state are read with GET. Any other POST is taken as a change,
+// actions such as refreshing the realization of an object included.
+var readPosts = []string{
+	"/api/session/create",
+	"/api/session/destroy",
+	"/search/aggregate",
+}
+
+// IsMutating tells whether req may change the NSX configuration.
+func IsMutating(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodPost, http.MethodPatch, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	for _, p := range readPosts {
+		if strings.HasSuffix(req.URL.Path, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadOnly refuses mutating requests without sending them. It is the transport level safeguard
+// of the readOnly setting, operations are normally rejected before reaching it.
+func ReadOnly(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if !IsMutating(req) {
+			return next.RoundTrip(req)
+		}
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		msg := fmt.Sprintf("%s %s refused: the provider is configured with readOnly", req.Method, req.URL.Path)
+		return errorResponse(req, http.StatusForbidden, msg), nil
+	})
+}
+
+// errorResponse builds a response shaped like an NSX API error, so that the upstream provider
+// reports msg as it would report an error from the manager.
+func errorResponse(req *http.Request, status int, msg string) *http.Response {
+	body, _ := json.Marshal(map[string]interface{}{
+		"httpStatus":    strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_")),
+		"error_code":    status,
+		"module_name":   "pulumi-nsxt",
+		"error_message": msg,
+	})
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	tests := []struct {
+		method, path string
+		sent         bool
+	}{
+		{http.MethodGet, "/policy/api/v1/infra/domains/default/groups/web", true},
+		{http.MethodHead, "/policy/api/v1/infra", true},
+		{http.MethodPost, "/policy/api/v1/infra/drafts/d?action=publish", false},
+		{http.MethodPatch, "/policy/api/v1/infra/domains/default/groups/web", false},
+		{http.MethodPut, "/policy/api/v1/infra/domains/default/groups/web", false},
+		{http.MethodDelete, "/policy/api/v1/infra/domains/default/groups/web", false},
+		{http.MethodPost, "/api/session/create", true},
+		{http.MethodPost, "/api/session/destroy", true},
+		{http.MethodPost, "/policy/api/v1/search/aggregate", true},
+		{http.MethodPost, "/api/session/create/more", false},
+	}
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.Method+" "+r.URL.Path)
+	}))
+	t.Cleanup(srv.Close)
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(base, Chain(http.DefaultTransport, ReadOnly), nil)
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			sent = nil
+			err := client.Do(context.Background(), tt.method, tt.path, map[string]interface{}{}, nil)
+			if (len(sent) == 1) != tt.sent {
+				t.Errorf("sent = %v, want sent %v", sent, tt.sent)
+			}
+			if tt.sent {
+				if err != nil {
+					t.Errorf("Do() error = %v", err)
+				}
+				return
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden ||
+				!strings.Contains(apiErr.Message, "configured with readOnly") {
+				t.Errorf("Do() error = %v, want a 403 refusal", err)
+			}
+		})
+	}
+}
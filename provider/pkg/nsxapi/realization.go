@@ -0,0 +1,55 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// realizationPollInterval is the delay between two checks of the realization of an object.
+const realizationPollInterval = 2 * time.Second
+
+// WaitForRealization waits until the Policy object at path is realized on the enforcement points,
+// or fails to be, or ctx is done.
+func (c *Client) WaitForRealization(ctx context.Context, path string) error {
+	query := "/infra/realized-state/status?intent_path=" + url.QueryEscape(path)
+	for {
+		var status struct {
+			ConsolidatedStatus struct {
+				Status string `json:"consolidated_status"`
+			} `json:"consolidated_status"`
+		}
+		if err := c.Do(ctx, "GET", c.PolicyAPI(query), nil, &status); err != nil {
+			return err
+		}
+		switch status.ConsolidatedStatus.Status {
+		case "SUCCESS":
+			return nil
+		case "ERROR":
+			return fmt.Errorf("realization of %s failed", path)
+		}
+
+		timer := time.NewTimer(realizationPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("realization of %s: %w", path, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/redact"
+)
+
+const (
+	xsrfHeader    = "X-Xsrf-Token"
+	sessionCookie = "JSESSIONID"
+	// credentialsErrorCode is the NSX error code of requests whose credentials, session included,
+	// are refused.
+	credentialsErrorCode = 98
+)
+
+// sessionTokens identify an NSX session.
+type sessionTokens struct {
+	cookie string // JSESSIONID value
+	xsrf   string
+}
+
+// Session returns a middleware keeping alive the session the upstream provider opens with
+// sessionAuth. The upstream provider authenticates once, so after the session expires every request
+// fails. When a request is refused for an expired session, the middleware opens a new session with
+// username and password, replays the request once with it, and from then on swaps the tokens of the
+// expired session for the new ones in every request. The new tokens are registered with redactor.
+func Session(username, password string, redactor *redact.Redactor) Middleware {
+	s := &sessionRenewer{
+		username: username,
+		password: password,
+		redactor: redactor,
+		replaced: map[string]*sessionTokens{},
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return s.roundTrip(next, req)
+		})
+	}
+}
+
+type sessionRenewer struct {
+	username, password string
+	redactor           *redact.Redactor
+
+	renewals singleflight.Group
+
+	mu sync.Mutex
+	// replaced maps the XSRF token of each expired session to the session replacing it.
+	replaced map[string]*sessionTokens
+}
+
+func (s *sessionRenewer) roundTrip(next http.RoundTripper, req *http.Request) (*http.Response, error) {
+	original := req.Header.Get(xsrfHeader)
+	if original == "" {
+		// Not sent within a session, session creation included.
+		return next.RoundTrip(req)
+	}
+	if _, err := bufferBody(req); err != nil {
+		return nil, err
+	}
+
+	sent := s.current(original)
+	resp, err := next.RoundTrip(withSession(req, sent))
+	if err != nil || !sessionExpired(resp) {
+		return resp, err
+	}
+
+	renewed, err := s.renew(next, req, original, sent)
+	if err != nil {
+		log.Printf("[WARN] NSX session expired and could not be renewed: %v", err)
+		return resp, nil
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	replay, err := rewind(req)
+	if err != nil {
+		return nil, err
+	}
+	return next.RoundTrip(withSession(replay, renewed))
+}
+
+// current returns the session to use in place of the one with the given XSRF token, or nil if it
+// hasn't expired.
+func (s *sessionRenewer) current(xsrf string) *sessionTokens {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.replaced[xsrf]
+}
+
+// renew opens a new session to replace the original one, unless sent, the session the request
+// failed with, was already replaced. Concurrent requests failing with the same session share a
+// single renewal.
+func (s *sessionRenewer) renew(next http.RoundTripper, req *http.Request, original string,
+	sent *sessionTokens) (*sessionTokens, error) {
+	expired := original
+	if sent != nil {
+		expired = sent.xsrf
+	}
+	tokens, err, _ := s.renewals.Do(expired, func() (interface{}, error) {
+		s.mu.Lock()
+		latest := s.replaced[original]
+		s.mu.Unlock()
+		if latest != nil && latest != sent {
+			return latest, nil
+		}
+
+		tokens, err := s.create(next, req)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// Every session replaced so far now leads to the new one.
+		for xsrf := range s.replaced {
+			s.replaced[xsrf] = tokens
+		}
+		s.replaced[original] = tokens
+		if sent != nil {
+			s.replaced[sent.xsrf] = tokens
+		}
+		trace.SpanFromContext(req.Context()).AddEvent("nsx.session_renewed")
+		log.Printf("[INFO] NSX session expired, opened a new one")
+		return tokens, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens.(*sessionTokens), nil
+}
+
+// create opens a session on the manager req is sent to.
+func (s *sessionRenewer) create(next http.RoundTripper, req *http.Request) (*sessionTokens, error) {
+	u := *req.URL
+	u.Path = "/api/session/create"
+	u.RawPath = ""
+	u.RawQuery = ""
+	form := url.Values{"j_username": {s.username}, "j_password": {s.password}}.Encode()
+	create, err := http.NewRequestWithContext(req.Context(), http.MethodPost, u.String(), strings.NewReader(form))
+	if err != nil {
+		return nil, err
+	}
+	create.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := next.RoundTrip(create)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("session creation failed with %s", resp.Status)
+	}
+
+	tokens := &sessionTokens{xsrf: resp.Header.Get(xsrfHeader)}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == sessionCookie {
+			tokens.cookie = cookie.Value
+		}
+	}
+	if tokens.xsrf == "" || tokens.cookie == "" {
+		return nil, fmt.Errorf("session creation returned no session token")
+	}
+	s.redactor.AddSecret(tokens.cookie)
+	s.redactor.AddSecret(tokens.xsrf)
+	return tokens, nil
+}
+
+// withSession returns req with its session tokens replaced by tokens, if not nil.
+func withSession(req *http.Request, tokens *sessionTokens) *http.Request {
+	if tokens == nil {
+		return req
+	}
+	req.Header.Set(xsrfHeader, tokens.xsrf)
+	cookies := req.Cookies()
+	req.Header.Del("Cookie")
+	found := false
+	for _, cookie := range cookies {
+		if cookie.Name == sessionCookie {
+			cookie.Value = tokens.cookie
+			found = true
+		}
+		req.AddCookie(cookie)
+	}
+	if !found {
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: tokens.cookie})
+	}
+	return req
+}
+
+// sessionExpired tells whether resp refuses a request because its session is no longer valid.
+// NSX answers 401, or 403 with the credentials error code or a message about the session or XSRF
+// token. The body of resp is left readable.
+func sessionExpired(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return true
+	case http.StatusForbidden:
+	default:
+		return false
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	var apiErr struct {
+		ErrorCode    int    `json:"error_code"`
+		ErrorMessage string `json:"error_message"`
+	}
+	if json.Unmarshal(body, &apiErr) != nil {
+		return false
+	}
+	msg := strings.ToLower(apiErr.ErrorMessage)
+	return apiErr.ErrorCode == credentialsErrorCode || strings.Contains(msg, "session") ||
+		strings.Contains(msg, "xsrf")
+}
@@ -0,0 +1,128 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/redact"
+)
+
+// sessionServer is an NSX stand-in whose sessions expire when a new one is created. Requests with
+// an expired session are refused with status.
+func sessionServer(t *testing.T, status int) (*httptest.Server, *int32) {
+	var creates int32
+	var mu sync.Mutex
+	current := "s0"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/session/create" {
+			_ = r.ParseForm()
+			if r.PostForm.Get("j_username") != "admin" || r.PostForm.Get("j_password") != "pass" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			// Slow enough for the concurrent requests to all fail with the expired session.
+			time.Sleep(50 * time.Millisecond)
+			n := atomic.AddInt32(&creates, 1)
+			mu.Lock()
+			current = fmt.Sprintf("s%d", n)
+			mu.Unlock()
+			w.Header().Set(xsrfHeader, "xsrf-"+current)
+			http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "cookie-" + current})
+			return
+		}
+		cookie, err := r.Cookie(sessionCookie)
+		mu.Lock()
+		valid := err == nil && cookie.Value == "cookie-"+current && r.Header.Get(xsrfHeader) == "xsrf-"+current
+		mu.Unlock()
+		if !valid {
+			w.WriteHeader(status)
+			_, _ = io.WriteString(w, `{"error_code":98,"error_message":"The credentials were incorrect."}`)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &creates
+}
+
+func TestSessionRenewal(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			srv, creates := sessionServer(t, status)
+			redactor := redact.NewRedactor()
+			client := &http.Client{Transport: Chain(http.DefaultTransport, Session("admin", "pass", redactor))}
+
+			// The session the upstream provider opened, expired since.
+			send := func(method string, body io.Reader) (*http.Response, error) {
+				req, err := http.NewRequest(method, srv.URL+"/policy/api/v1/infra/segments/web", body)
+				if err != nil {
+					return nil, err
+				}
+				req.Header.Set(xsrfHeader, "xsrf-expired")
+				req.AddCookie(&http.Cookie{Name: sessionCookie, Value: "cookie-expired"})
+				return client.Do(req)
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 10)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					body := strings.NewReader(fmt.Sprintf(`{"display_name":"web-%d"}`, i))
+					resp, err := send(http.MethodPatch, body)
+					if err != nil {
+						errs <- err
+						return
+					}
+					defer resp.Body.Close()
+					if resp.StatusCode != http.StatusOK {
+						errs <- fmt.Errorf("got %s", resp.Status)
+					}
+				}(i)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+			if n := atomic.LoadInt32(creates); n != 1 {
+				t.Errorf("%d sessions created, want 1", n)
+			}
+
+			// Later requests with the expired session use the new one right away.
+			resp, err := send(http.MethodGet, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || atomic.LoadInt32(creates) != 1 {
+				t.Errorf("got %s after %d session creations", resp.Status, atomic.LoadInt32(creates))
+			}
+			if got := redactor.String("cookie-s1 xsrf-s1"); strings.Contains(got, "s1") {
+				t.Errorf("new session tokens not registered with the redactor: %q", got)
+			}
+		})
+	}
+}
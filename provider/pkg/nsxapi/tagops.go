@@ -0,0 +1,100 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	// tagOperationPollInterval is the delay between two checks of the status of a tag operation.
+	tagOperationPollInterval = 2 * time.Second
+	// tagOperationCleanupTimeout bounds the deletion of a tag operation once over.
+	tagOperationCleanupTimeout = 30 * time.Second
+)
+
+// TagOperation applies a tag to resources of a type, and removes it from others, in a single
+// bulk operation.
+type TagOperation struct {
+	ResourceType string // such as VirtualMachine
+	Scope        string
+	Tag          string
+	ApplyTo      []string // IDs of the resources, external IDs for VMs
+	RemoveFrom   []string
+}
+
+// RunTagOperation starts op under the given ID and waits until NSX is done with it, or ctx is done.
+// The operation is deleted once over.
+func (c *Client) RunTagOperation(ctx context.Context, id string, op TagOperation) error {
+	targets := func(ids []string) []interface{} {
+		if len(ids) == 0 {
+			return []interface{}{}
+		}
+		return []interface{}{map[string]interface{}{"resource_type": op.ResourceType, "resource_ids": ids}}
+	}
+	payload := map[string]interface{}{
+		"tag":         map[string]interface{}{"scope": op.Scope, "tag": op.Tag},
+		"apply_to":    targets(op.ApplyTo),
+		"remove_from": targets(op.RemoveFrom),
+	}
+	path := c.PolicyAPI("/infra/tags/tag-operations/" + url.PathEscape(id))
+	if err := c.Do(ctx, "PUT", path, payload, nil); err != nil {
+		return err
+	}
+	defer func() {
+		// Operations are kept by NSX until deleted, a leftover one is harmless. The deletion is
+		// attempted even when ctx is done, keeping its values.
+		cleanupCtx, cancel := context.WithTimeout(detached{ctx}, tagOperationCleanupTimeout)
+		defer cancel()
+		_ = c.Do(cleanupCtx, "DELETE", path, nil, nil)
+	}()
+
+	for {
+		var status struct {
+			Status string `json:"status"`
+		}
+		if err := c.Do(ctx, "GET", path+"/status", nil, &status); err != nil {
+			return err
+		}
+		switch status.Status {
+		case "SUCCESS":
+			return nil
+		case "", "NOT_STARTED", "IN_PROGRESS":
+		default:
+			return fmt.Errorf("tag operation %s (%s:%s) ended with status %s", id, op.Scope, op.Tag, status.Status)
+		}
+
+		timer := time.NewTimer(tagOperationPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("tag operation %s: %w", id, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// detached carries the values of a context but not its deadline and cancellation, as
+// context.WithoutCancel does from Go 1.21 on.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
@@ -0,0 +1,50 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunTagOperationCleanup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var calls []string
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls = append(calls, r.Method+" "+strings.TrimPrefix(r.URL.Path, "/policy/api/v1/infra/tags/tag-operations/"))
+		mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/status") {
+			// The caller gives up while the operation runs.
+			cancel()
+			_, _ = io.WriteString(w, `{"status":"IN_PROGRESS"}`)
+		}
+	})
+
+	err := c.RunTagOperation(ctx, "op", TagOperation{ResourceType: "VirtualMachine", Scope: "env", Tag: "prod",
+		ApplyTo: []string{"vm-1"}})
+	if err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Errorf("RunTagOperation() error = %v, want canceled", err)
+	}
+	want := []string{"PUT op", "GET op/status", "DELETE op"}
+	if strings.Join(calls, ", ") != strings.Join(want, ", ") {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
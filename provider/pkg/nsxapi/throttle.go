@@ -0,0 +1,203 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// maxThrottleRetries bounds how many times a request is retried after a Retry-After.
+	maxThrottleRetries = 3
+	// maxRetryAfter caps the delay asked for by a Retry-After header.
+	maxRetryAfter = time.Minute
+)
+
+// Limits bound the load the provider puts on the NSX API. Zero means unlimited.
+type Limits struct {
+	MaxConcurrentRequests int
+	RequestsPerSecond     float64
+}
+
+// Throttle returns a middleware enforcing limits on the requests going through it, whichever
+// operation they belong to. Requests answered with HTTP 429 or 503 and a Retry-After header are
+// retried after the given delay, during which no other request is sent. Without Retry-After the
+// response is left to the retry logic of the upstream provider. The time requests spend waiting
+// and the retries are added up in ops, and recorded on the span of the request.
+func Throttle(limits Limits, ops *Operations) Middleware {
+	t := &throttle{ops: ops}
+	if limits.MaxConcurrentRequests > 0 {
+		t.slots = make(chan struct{}, limits.MaxConcurrentRequests)
+	}
+	if limits.RequestsPerSecond > 0 {
+		burst := int(math.Ceil(limits.RequestsPerSecond))
+		t.limiter = rate.NewLimiter(rate.Limit(limits.RequestsPerSecond), burst)
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return t.roundTrip(next, req)
+		})
+	}
+}
+
+type throttle struct {
+	ops     *Operations
+	slots   chan struct{}
+	limiter *rate.Limiter
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+func (t *throttle) roundTrip(next http.RoundTripper, req *http.Request) (*http.Response, error) {
+	// The body is buffered so that the request can be retried.
+	_, err := bufferBody(req)
+	if err != nil {
+		return nil, err
+	}
+	span := trace.SpanFromContext(req.Context())
+
+	for retry := 0; ; retry++ {
+		if retry > 0 {
+			if req, err = rewind(req); err != nil {
+				return nil, err
+			}
+		}
+		start := time.Now()
+		if err := t.acquire(req.Context()); err != nil {
+			return nil, err
+		}
+		waited := time.Since(start)
+		t.ops.addThrottling(waited, 0)
+		if waited >= time.Millisecond {
+			span.AddEvent("nsx.throttled", trace.WithAttributes(attribute.Int64("nsx.wait_ms", waited.Milliseconds())))
+		}
+
+		resp, err := next.RoundTrip(req)
+		t.release()
+		if retry > 0 {
+			span.SetAttributes(attribute.Int("nsx.retry_count", retry))
+		}
+		if err != nil || retry == maxThrottleRetries {
+			return resp, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+			return resp, nil
+		}
+		delay, ok := retryAfter(resp.Header.Get("Retry-After"))
+		if !ok {
+			return resp, nil
+		}
+
+		log.Printf("[DEBUG] NSX answered %s %s with %d, retrying in %s", req.Method, req.URL.Path,
+			resp.StatusCode, delay)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		t.pause(delay)
+		span.AddEvent("nsx.retry_after", trace.WithAttributes(
+			attribute.Int("http.status_code", resp.StatusCode), attribute.Int64("nsx.delay_ms", delay.Milliseconds())))
+		t.ops.addThrottling(0, 1)
+	}
+}
+
+// acquire waits for a request slot, a rate limiter token and the end of any pause.
+func (t *throttle) acquire(ctx context.Context) error {
+	t.mu.Lock()
+	wait := time.Until(t.pausedUntil)
+	t.mu.Unlock()
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	if t.limiter != nil {
+		if err := t.limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if t.slots != nil {
+		select {
+		case t.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (t *throttle) release() {
+	if t.slots != nil {
+		<-t.slots
+	}
+}
+
+// pause holds every request for delay, the manager asking for it is overloaded as a whole.
+func (t *throttle) pause(delay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until := time.Now().Add(delay); until.After(t.pausedUntil) {
+		t.pausedUntil = until
+	}
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP date.
+func retryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		delay = time.Until(date)
+	} else {
+		return 0, false
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > maxRetryAfter {
+		delay = maxRetryAfter
+	}
+	return delay, true
+}
+
+// rewind returns a copy of req with a fresh body, to send it again.
+func rewind(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
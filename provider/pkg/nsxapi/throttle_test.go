@@ -0,0 +1,185 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// throttledClient returns a client sending its requests through Throttle to handler.
+func throttledClient(t *testing.T, limits Limits, ops *Operations, handler http.HandlerFunc) (*http.Client, string) {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &http.Client{Transport: Chain(http.DefaultTransport, Throttle(limits, ops))}, srv.URL
+}
+
+func TestThrottleConcurrentRequests(t *testing.T) {
+	var inFlight, most int32
+	client, url := throttledClient(t, Limits{MaxConcurrentRequests: 2}, NewOperations(),
+		func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				m := atomic.LoadInt32(&most)
+				if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+		})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(url)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	if most != 2 {
+		t.Errorf("at most %d requests in flight, want 2", most)
+	}
+}
+
+func TestThrottleRate(t *testing.T) {
+	ops := NewOperations()
+	client, url := throttledClient(t, Limits{RequestsPerSecond: 10}, ops, func(http.ResponseWriter, *http.Request) {})
+
+	// The first 10 requests are a burst, the next 5 are spaced by 100ms.
+	start := time.Now()
+	for i := 0; i < 15; i++ {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("15 requests at 10 per second took %s", elapsed)
+	}
+	if waited, _ := ops.Throttling(); waited < 400*time.Millisecond {
+		t.Errorf("Throttling() waited %s, want at least 400ms", waited)
+	}
+}
+
+func TestThrottleRetryAfter(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		retryAfter  string
+		failures    int
+		wantStatus  int
+		wantSent    int
+		wantRetries int
+	}{
+		{name: "retried", status: http.StatusTooManyRequests, retryAfter: "0", failures: 2,
+			wantStatus: http.StatusOK, wantSent: 3, wantRetries: 2},
+		{name: "service unavailable", status: http.StatusServiceUnavailable, retryAfter: "0", failures: 1,
+			wantStatus: http.StatusOK, wantSent: 2, wantRetries: 1},
+		{name: "retries capped", status: http.StatusTooManyRequests, retryAfter: "0", failures: 10,
+			wantStatus: http.StatusTooManyRequests, wantSent: maxThrottleRetries + 1, wantRetries: maxThrottleRetries},
+		{name: "no Retry-After", status: http.StatusTooManyRequests, failures: 1,
+			wantStatus: http.StatusTooManyRequests, wantSent: 1},
+		{name: "other status", status: http.StatusInternalServerError, retryAfter: "0", failures: 1,
+			wantStatus: http.StatusInternalServerError, wantSent: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := NewOperations()
+			var sent int32
+			client, url := throttledClient(t, Limits{MaxConcurrentRequests: 1}, ops,
+				func(w http.ResponseWriter, r *http.Request) {
+					n := atomic.AddInt32(&sent, 1)
+					if body, _ := io.ReadAll(r.Body); string(body) != `{"a":1}` {
+						t.Errorf("request %d body = %q", n, body)
+					}
+					if int(n) <= tt.failures {
+						if tt.retryAfter != "" {
+							w.Header().Set("Retry-After", tt.retryAfter)
+						}
+						w.WriteHeader(tt.status)
+					}
+				})
+
+			resp, err := client.Post(url, "application/json", strings.NewReader(`{"a":1}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if n := int(atomic.LoadInt32(&sent)); resp.StatusCode != tt.wantStatus || n != tt.wantSent {
+				t.Errorf("status %d after %d requests, want %d after %d", resp.StatusCode, n, tt.wantStatus,
+					tt.wantSent)
+			}
+			if _, retries := ops.Throttling(); retries != tt.wantRetries {
+				t.Errorf("Throttling() retries = %d, want %d", retries, tt.wantRetries)
+			}
+		})
+	}
+}
+
+func TestThrottlePause(t *testing.T) {
+	th := &throttle{ops: NewOperations()}
+	th.pause(200 * time.Millisecond)
+	// A shorter pause doesn't cut the longer one short.
+	th.pause(time.Millisecond)
+
+	start := time.Now()
+	if err := th.acquire(httptest.NewRequest("GET", "/", nil).Context()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("request sent %s into a 200ms pause", elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{header: "", ok: false},
+		{header: "soon", ok: false},
+		{header: "5", want: 5 * time.Second, ok: true},
+		{header: "-5", want: 0, ok: true},
+		{header: "3600", want: maxRetryAfter, ok: true},
+		{header: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), want: 0, ok: true},
+		{header: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), want: maxRetryAfter, ok: true},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.header)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %s, %v, want %s, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	date := time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat)
+	if got, ok := retryAfter(date); !ok || got < 28*time.Second || got > 30*time.Second {
+		t.Errorf("retryAfter(%q) = %s, %v, want about 30s", date, got, ok)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/telemetry"
+)
+
+// Tracing returns a middleware recording a span per NSX request. The span is a child of the span
+// of the resource operation the request is attributed to by ops.
+func Tracing(ops *Operations) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			parent := context.Background()
+			attrs := []attribute.KeyValue{
+				semconv.HTTPMethod(req.Method),
+				attribute.String("nsx.path", policyPath(req.URL.Path)),
+			}
+			if op := ops.Of(req); op != nil && op.Context != nil {
+				parent = op.Context
+			}
+			_, span := telemetry.Tracer().Start(parent, "NSX "+req.Method,
+				trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+			defer span.End()
+
+			// The throttle and session middlewares, inner ones, add their waits, retries and
+			// re-authentications to the span.
+			resp, err := next.RoundTrip(req.WithContext(trace.ContextWithSpan(req.Context(), span)))
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return resp, err
+			}
+			span.SetAttributes(semconv.HTTPStatusCode(resp.StatusCode))
+			if id := resp.Header.Get(requestIDHeader); id != "" {
+				span.SetAttributes(attribute.String("nsx.request_id", id))
+			}
+			if resp.StatusCode >= http.StatusBadRequest {
+				span.SetStatus(codes.Error, resp.Status)
+			}
+			return resp, nil
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+)
+
+// TransportOptions mirror the connection settings of the upstream provider.
+type TransportOptions struct {
+	// CA is a PEM bundle of trusted certificates. The system pool is used when empty.
+	CA []byte
+	// Insecure disables server certificate verification (allowUnverifiedSsl).
+	Insecure bool
+	// ClientCert and ClientKey are the PEM client certificate and key, if any.
+	ClientCert []byte
+	ClientKey  []byte
+	// Fingerprints pin the server certificate instead of verifying it against CA: it is trusted when
+	// it has one of these SHA-256 fingerprints, or verifies against a CA certificate sent with it
+	// that has one.
+	Fingerprints [][]byte
+	// ServerName is the host name or IP address of the server, which the certificate must be issued
+	// for when it is verified against a pinned CA.
+	ServerName string
+	// Proxy, if not nil, replaces the proxy settings of the environment.
+	Proxy *Proxy
+}
+
+// NewTransport returns the transport used to talk to the manager itself.
+func NewTransport(opts TransportOptions) (*http.Transport, error) {
+	//nolint:gosec // InsecureSkipVerify is an explicit user setting.
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.Insecure,
+	}
+	if len(opts.CA) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(opts.CA) {
+			return nil, fmt.Errorf("no valid certificate found in the CA bundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(opts.ClientCert) > 0 || len(opts.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if len(opts.Fingerprints) > 0 {
+		// Self-signed managers don't verify against any pool, the pinned fingerprint is checked
+		// instead once the handshake is done.
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = verifyFingerprints(opts.Fingerprints, opts.ServerName)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if opts.Proxy != nil {
+		opts.Proxy.apply(transport)
+	}
+	return transport, nil
+}
+
+// Middleware decorates the transport to the manager.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// Chain wraps rt in middlewares, the first one being the outermost.
+func Chain(rt http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		rt = middlewares[i](rt)
+	}
+	return rt
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,108 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Version is an NSX version, such as 4.1.2.0.0.22589037: major, minor and patch numbers followed by
+// the build number.
+type Version []int
+
+// ParseVersion parses a dotted NSX version.
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	for _, part := range strings.Split(strings.TrimSpace(s), ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NSX version %q", s)
+		}
+		v = append(v, n)
+	}
+	return v, nil
+}
+
+// AtLeast tells whether v is min or later, missing numbers counting as 0.
+func (v Version) AtLeast(min Version) bool {
+	for i := 0; i < len(v) || i < len(min); i++ {
+		var a, b int
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(min) {
+			b = min[i]
+		}
+		if a != b {
+			return a > b
+		}
+	}
+	return true
+}
+
+func (v Version) String() string {
+	parts := make([]string, len(v))
+	for i, n := range v {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
+// versionPaths are where the version of the manager is read from, in order: the Policy API, which
+// VMC exposes, then the Manager API.
+var versionPaths = []string{"/policy/api/v1/node/version", "/api/v1/node/version"}
+
+// NodeVersion is the version information of the manager.
+type NodeVersion struct {
+	NodeVersion    string `json:"node_version"`
+	ProductVersion string `json:"product_version"`
+}
+
+// Version returns the product version, or the node version if the product one isn't set.
+func (v *NodeVersion) Version() (Version, error) {
+	if v.ProductVersion != "" {
+		return ParseVersion(v.ProductVersion)
+	}
+	return ParseVersion(v.NodeVersion)
+}
+
+// NodeVersion returns the version information of the manager, from the first API of versionPaths
+// the manager serves.
+func (c *Client) NodeVersion(ctx context.Context) (*NodeVersion, error) {
+	var err error
+	for _, path := range versionPaths {
+		var v NodeVersion
+		if err = c.Do(ctx, "GET", path, nil, &v); err == nil {
+			return &v, nil
+		}
+		if !isUnavailable(err) {
+			break
+		}
+	}
+	return nil, fmt.Errorf("cannot read the NSX version: %w", err)
+}
+
+// isUnavailable tells whether err is the answer of a manager that doesn't serve an API, or not to
+// the provider: a 404 or 403.
+func isUnavailable(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) &&
+		(apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusForbidden)
+}
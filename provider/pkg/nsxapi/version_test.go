@@ -0,0 +1,101 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+// testClient returns a client of an NSX stand-in serving handler.
+func testClient(t *testing.T, handler http.HandlerFunc) *Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewClient(base, http.DefaultTransport, nil)
+}
+
+func TestNodeVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		served map[string]string
+		want   string
+	}{
+		{
+			name:   "policy API",
+			served: map[string]string{"/policy/api/v1/node/version": `{"product_version":"4.1.2.0.0.22589037"}`},
+			want:   "4.1.2.0.0.22589037",
+		},
+		{
+			name:   "manager API",
+			served: map[string]string{"/api/v1/node/version": `{"node_version":"3.2.1.0.0.19801963"}`},
+			want:   "3.2.1.0.0.19801963",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+				body, ok := tt.served[r.URL.Path]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = io.WriteString(w, body)
+			})
+			v, err := c.NodeVersion(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			version, err := v.Version()
+			if err != nil || version.String() != tt.want {
+				t.Errorf("Version() = %v, %v, want %s", version, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectionVersionRetriesFailures(t *testing.T) {
+	var calls int32
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, `{"product_version":"4.1.0"}`)
+	})
+	conn := NewConnection()
+	conn.SetClient(c)
+
+	if _, err := conn.Version(context.Background()); err == nil {
+		t.Fatal("Version() succeeded while the manager failed")
+	}
+	for i := 0; i < 2; i++ {
+		v, err := conn.Version(context.Background())
+		if err != nil || v.String() != "4.1.0" {
+			t.Fatalf("Version() = %v, %v", v, err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("%d requests, want 2: a failure then a single successful read", n)
+	}
+}
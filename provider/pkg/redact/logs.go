@@ -0,0 +1,130 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// headerPattern matches the credentials HTTP headers carry, as dumped by net/http/httputil
+	// ("Cookie: ...") or printed from an http.Header ("map[Cookie:[...]]") or as JSON.
+	headerPattern = regexp.MustCompile(
+		`(?i)((?:proxy-)?authorization|set-cookie|cookie|x-xsrf-token)("?\s*[:=]\s*"?\[?)[^\r\n\]"]*`)
+	// jsonPattern matches string members of JSON documents.
+	jsonPattern = regexp.MustCompile(`"([\w-]+)"(\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// quotedJSONPattern matches string members of JSON documents quoted in another string.
+	quotedJSONPattern = regexp.MustCompile(`\\"([\w-]+)\\"(\s*:\s*)\\"(?:[^"\\]|\\[^"])*\\"`)
+	// formPattern matches form and query parameters, such as j_password in session requests.
+	formPattern = regexp.MustCompile(`\b([\w-]+)=([^&\s"]+)`)
+)
+
+// Redactor masks secrets in log messages: the values of the sensitive JSON keys and form
+// parameters, credential headers, and the values registered with AddSecret.
+type Redactor struct {
+	mu       sync.RWMutex
+	secrets  map[string]bool
+	replacer *strings.Replacer
+}
+
+// NewRedactor returns a Redactor with no registered secret.
+func NewRedactor() *Redactor {
+	return &Redactor{secrets: map[string]bool{}}
+}
+
+// AddSecret registers a value to mask wherever it appears, such as a configured password.
+func (r *Redactor) AddSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.secrets[secret] {
+		return
+	}
+	r.secrets[secret] = true
+
+	// Longest first, so that a secret containing another one is masked as a whole.
+	secrets := make([]string, 0, len(r.secrets))
+	for s := range r.secrets {
+		secrets = append(secrets, s)
+	}
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, s := range secrets {
+		pairs = append(pairs, s, Mask)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// String returns s with its secrets masked.
+func (r *Redactor) String(s string) string {
+	r.mu.RLock()
+	replacer := r.replacer
+	r.mu.RUnlock()
+	if replacer != nil {
+		s = replacer.Replace(s)
+	}
+
+	s = headerPattern.ReplaceAllString(s, "${1}${2}"+Mask)
+	s = replaceSensitive(jsonPattern, s, func(key, sep string) string {
+		return `"` + key + `"` + sep + `"` + Mask + `"`
+	})
+	s = replaceSensitive(quotedJSONPattern, s, func(key, sep string) string {
+		return `\"` + key + `\"` + sep + `\"` + Mask + `\"`
+	})
+	return replaceSensitive(formPattern, s, func(key, _ string) string {
+		return key + "=" + Mask
+	})
+}
+
+// Filter implements the log filter interface of the Pulumi SDK.
+func (r *Redactor) Filter(s string) string {
+	return r.String(s)
+}
+
+// replaceSensitive replaces the matches of pattern whose first group is a sensitive key with the
+// result of masked, which receives the first two groups.
+func replaceSensitive(pattern *regexp.Regexp, s string, masked func(key, sep string) string) string {
+	return pattern.ReplaceAllStringFunc(s, func(match string) string {
+		groups := pattern.FindStringSubmatch(match)
+		if !IsSensitiveKey(strings.TrimPrefix(groups[1], "j_")) {
+			return match
+		}
+		return masked(groups[1], groups[2])
+	})
+}
+
+// NewWriter returns a writer redacting what it writes to w. Each write is redacted on its own, as
+// the log package writes one whole message at a time.
+func NewWriter(w io.Writer, r *Redactor) io.Writer {
+	return &writer{w: w, r: r}
+}
+
+type writer struct {
+	w io.Writer
+	r *Redactor
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.r.String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
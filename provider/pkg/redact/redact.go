@@ -0,0 +1,86 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact masks credentials and other secrets in NSX payloads before they are written
+// anywhere.
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Mask replaces redacted values.
+const Mask = "********"
+
+// sensitiveKeys are the JSON keys (compared lowercase, ignoring '_' and '-') whose values are
+// never kept. They cover the NSX objects carrying secrets: IPsec pre-shared keys, BGP and OSPF
+// passwords, certificate private keys, user passwords and API tokens.
+var sensitiveKeys = map[string]bool{
+	"password":          true,
+	"oldpassword":       true,
+	"newpassword":       true,
+	"psk":               true,
+	"presharedkey":      true,
+	"secret":            true,
+	"sharedsecret":      true,
+	"privatekey":        true,
+	"passphrase":        true,
+	"token":             true,
+	"accesstoken":       true,
+	"refreshtoken":      true,
+	"apitoken":          true,
+	"authenticationkey": true,
+	"authkey":           true,
+}
+
+// IsSensitiveKey tells whether values stored under key are secrets.
+func IsSensitiveKey(key string) bool {
+	k := strings.ToLower(key)
+	k = strings.NewReplacer("_", "", "-", "").Replace(k)
+	return sensitiveKeys[k]
+}
+
+// JSON returns a copy of the JSON document body with the values of sensitive keys masked. ok is
+// false when body isn't JSON, callers must then not use body as is.
+func JSON(body []byte) (redacted []byte, ok bool) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, false
+	}
+	redacted, err := json.Marshal(Value(doc))
+	if err != nil {
+		return nil, false
+	}
+	return redacted, true
+}
+
+// Value masks the sensitive keys of a decoded JSON value in place and returns it.
+func Value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if IsSensitiveKey(k) && child != nil {
+				v[k] = Mask
+				continue
+			}
+			v[k] = Value(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = Value(child)
+		}
+	}
+	return v
+}
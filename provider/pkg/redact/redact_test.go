@@ -0,0 +1,122 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactorString(t *testing.T) {
+	r := NewRedactor()
+	r.AddSecret("s3cr3t-Passw0rd")
+	r.AddSecret("vmc-api-token-1234")
+
+	tests := []struct {
+		name    string
+		message string
+		secrets []string
+	}{
+		{
+			name:    "password",
+			message: "[DEBUG] connecting with password s3cr3t-Passw0rd",
+			secrets: []string{"s3cr3t-Passw0rd"},
+		},
+		{
+			name:    "vmc token",
+			message: "POST /csp/gateway/am/api/auth/api-tokens/authorize refresh_token=vmc-api-token-1234",
+			secrets: []string{"vmc-api-token-1234"},
+		},
+		{
+			name:    "session cookie",
+			message: "GET /policy/api/v1/infra HTTP/1.1\r\nCookie: JSESSIONID=A1B2C3D4\r\nAccept: */*",
+			secrets: []string{"A1B2C3D4"},
+		},
+		{
+			name:    "set-cookie",
+			message: "HTTP/1.1 200 OK\r\nSet-Cookie: JSESSIONID=E5F6; Path=/; Secure\r\n",
+			secrets: []string{"E5F6"},
+		},
+		{
+			name:    "xsrf header",
+			message: "HTTP/1.1 200 OK\r\nX-XSRF-TOKEN: 0f1e2d3c-4b5a\r\n",
+			secrets: []string{"0f1e2d3c-4b5a"},
+		},
+		{
+			name:    "header map",
+			message: "headers: map[Authorization:[Basic YWRtaW46cGFzcw==] X-Xsrf-Token:[abcd-ef]]",
+			secrets: []string{"YWRtaW46cGFzcw==", "abcd-ef"},
+		},
+		{
+			name:    "json payload",
+			message: `{"display_name":"vpn","psk":"p5k-value","ike_profile":{"private_key":"PEM"}}`,
+			secrets: []string{"p5k-value", "PEM"},
+		},
+		{
+			name:    "quoted json payload",
+			message: `body: "{\"enable\":true,\"password\":\"bgp-pass\"}"`,
+			secrets: []string{"bgp-pass"},
+		},
+		{
+			name:    "session form",
+			message: "POST /api/session/create j_username=admin&j_password=form-pass",
+			secrets: []string{"form-pass"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.String(tt.message)
+			for _, secret := range tt.secrets {
+				if strings.Contains(got, secret) {
+					t.Errorf("String(%q) = %q, still contains %q", tt.message, got, secret)
+				}
+			}
+			if !strings.Contains(got, Mask) {
+				t.Errorf("String(%q) = %q, masks nothing", tt.message, got)
+			}
+		})
+	}
+}
+
+func TestRedactorStringKeepsPlainValues(t *testing.T) {
+	message := `{"display_name":"web","tags":[{"scope":"env","tag":"prod"}]} page_size=1000`
+	if got := NewRedactor().String(message); got != message {
+		t.Errorf("String(%q) = %q", message, got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"top level", `{"password":"x","name":"a"}`, `{"name":"a","password":"********"}`},
+		{"nested", `{"peer":{"pre_shared_key":"x"}}`, `{"peer":{"pre_shared_key":"********"}}`},
+		{"array", `[{"auth-key":"x"},{"key":"y"}]`, `[{"auth-key":"********"},{"key":"y"}]`},
+		{"null kept", `{"secret":null}`, `{"secret":null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := JSON([]byte(tt.body))
+			if !ok || string(got) != tt.want {
+				t.Errorf("JSON(%s) = %s, %v, want %s", tt.body, got, ok, tt.want)
+			}
+		})
+	}
+	if _, ok := JSON([]byte("j_password=x")); ok {
+		t.Error("JSON accepted a form body")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pulumi/pulumi/pkg/v3/resource/provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/rpcutil"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// serveEngine starts a local engine endpoint forwarding to the Pulumi engine at target, with log
+// messages passed through filter, and returns its address. The bridge sends the output of the
+// upstream provider, debug HTTP dumps included, to the engine as log messages.
+func serveEngine(target string, filter func(string) string) (string, error) {
+	conn, err := grpc.Dial(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()), rpcutil.GrpcChannelOptions())
+	if err != nil {
+		return "", err
+	}
+	handle, err := rpcutil.ServeWithOptions(rpcutil.ServeOptions{
+		Init: func(srv *grpc.Server) error {
+			pulumirpc.RegisterEngineServer(srv, &filteringEngine{
+				engine: pulumirpc.NewEngineClient(conn),
+				filter: filter,
+			})
+			return nil
+		},
+	})
+	if err != nil {
+		conn.Close()
+		return "", err
+	}
+	return fmt.Sprintf("127.0.0.1:%d", handle.Port), nil
+}
+
+type filteringEngine struct {
+	pulumirpc.UnimplementedEngineServer
+
+	engine pulumirpc.EngineClient
+	filter func(string) string
+}
+
+func (e *filteringEngine) Log(ctx context.Context, req *pulumirpc.LogRequest) (*emptypb.Empty, error) {
+	req.Message = e.filter(req.GetMessage())
+	return e.engine.Log(ctx, req)
+}
+
+func (e *filteringEngine) GetRootResource(ctx context.Context,
+	req *pulumirpc.GetRootResourceRequest) (*pulumirpc.GetRootResourceResponse, error) {
+	return e.engine.GetRootResource(ctx, req)
+}
+
+func (e *filteringEngine) SetRootResource(ctx context.Context,
+	req *pulumirpc.SetRootResourceRequest) (*pulumirpc.SetRootResourceResponse, error) {
+	return e.engine.SetRootResource(ctx, req)
+}
+
+// filterAttach points the bridge at a filtering engine endpoint when it is attached to an engine,
+// which happens instead of the command line argument when debugging the provider.
+func filterAttach(filter func(string) string) Middleware {
+	return func(_ *provider.HostClient, next pulumirpc.ResourceProviderServer) pulumirpc.ResourceProviderServer {
+		return &attachServer{ResourceProviderServer: next, filter: filter}
+	}
+}
+
+type attachServer struct {
+	pulumirpc.ResourceProviderServer
+
+	filter func(string) string
+}
+
+func (s *attachServer) Attach(ctx context.Context, req *pulumirpc.PluginAttach) (*emptypb.Empty, error) {
+	addr, err := serveEngine(req.GetAddress(), s.filter)
+	if err != nil {
+		return nil, err
+	}
+	req.Address = addr
+	return s.ResourceProviderServer.Attach(ctx, req)
+}
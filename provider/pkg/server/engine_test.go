@@ -0,0 +1,128 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pulumi/pulumi/pkg/v3/resource/provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/rpcutil"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/redact"
+)
+
+type recordingEngine struct {
+	pulumirpc.UnimplementedEngineServer
+
+	mu       sync.Mutex
+	messages []string
+}
+
+func (e *recordingEngine) Log(_ context.Context, req *pulumirpc.LogRequest) (*emptypb.Empty, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.messages = append(e.messages, req.GetMessage())
+	return &emptypb.Empty{}, nil
+}
+
+// sharedHost is the host client of the tests, logging to sharedEngine. Each new host client
+// replaces the global gRPC logger, racing with the connections already open, so that it is created
+// once before the tests run.
+var (
+	sharedHost   *provider.HostClient
+	sharedEngine = &recordingEngine{}
+)
+
+func TestMain(m *testing.M) {
+	handle, err := rpcutil.ServeWithOptions(rpcutil.ServeOptions{
+		Init: func(srv *grpc.Server) error {
+			pulumirpc.RegisterEngineServer(srv, sharedEngine)
+			return nil
+		},
+	})
+	if err == nil {
+		sharedHost, err = provider.NewHostClient(fmt.Sprintf("127.0.0.1:%d", handle.Port))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// testHost returns a host client logging to a recording engine, with no message yet.
+func testHost(t *testing.T) (*provider.HostClient, *recordingEngine) {
+	sharedEngine.mu.Lock()
+	defer sharedEngine.mu.Unlock()
+	sharedEngine.messages = nil
+	return sharedHost, sharedEngine
+}
+
+// logged returns the messages the engine got.
+func (e *recordingEngine) logged() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.messages...)
+}
+
+func TestServeEngineRedacts(t *testing.T) {
+	engine := &recordingEngine{}
+	handle, err := rpcutil.ServeWithOptions(rpcutil.ServeOptions{
+		Init: func(srv *grpc.Server) error {
+			pulumirpc.RegisterEngineServer(srv, engine)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redactor := redact.NewRedactor()
+	redactor.AddSecret("s3cr3t-Passw0rd")
+	addr, err := serveEngine(fmt.Sprintf("127.0.0.1:%d", handle.Port), redactor.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = pulumirpc.NewEngineClient(conn).Log(context.Background(), &pulumirpc.LogRequest{
+		Message: "[DEBUG] configured for admin with s3cr3t-Passw0rd",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	if len(engine.messages) != 1 {
+		t.Fatalf("engine got %d messages, want 1", len(engine.messages))
+	}
+	if msg := engine.messages[0]; strings.Contains(msg, "s3cr3t-Passw0rd") || !strings.Contains(msg, redact.Mask) {
+		t.Errorf("engine got %q", msg)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package server serves the bridged provider behind gRPC middlewares that need to see the
+// Pulumi side of an operation (URN, preview flag) which the Terraform provider never gets.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfbridge"
+	"github.com/pulumi/pulumi/pkg/v3/resource/provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/cmdutil"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/contract"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/logging"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/redact"
+)
+
+// Middleware wraps a provider server. Middlewares embed the server they wrap and only override
+// the methods they care about.
+type Middleware func(host *provider.HostClient, next pulumirpc.ResourceProviderServer) pulumirpc.ResourceProviderServer
+
+// Main launches the bridged provider exactly like tfbridge.Main, but serves it behind the given
+// middlewares. The first middleware is the outermost one. Every log message of the provider is
+// passed through redactor.
+func Main(pkg string, version string, prov tfbridge.ProviderInfo, pulumiSchema []byte,
+	redactor *redact.Redactor, middlewares ...Middleware) {
+	// Mirror the flags handled by tfbridge.Main, tfgen and the build rely on them.
+	flags := flag.NewFlagSet("tf-provider-flags", flag.ContinueOnError)
+	defaultOutput := flags.Output()
+	flags.SetOutput(io.Discard)
+
+	dumpInfo := flags.Bool("get-provider-info", false, "dump provider info as JSON to stdout")
+	providerVersion := flags.Bool("version", false, "get built provider version")
+
+	err := flags.Parse(os.Args[1:])
+	contract.IgnoreError(err)
+
+	if err == flag.ErrHelp {
+		flags.SetOutput(defaultOutput)
+		if err := flags.Parse(os.Args[1:]); err != nil {
+			cmdutil.ExitError(err.Error())
+		}
+	}
+
+	if *dumpInfo {
+		if err := json.NewEncoder(os.Stdout).Encode(tfbridge.MarshalProviderInfo(&prov)); err != nil {
+			cmdutil.ExitError(err.Error())
+		}
+		os.Exit(0)
+	}
+
+	if *providerVersion {
+		fmt.Println(version)
+		os.Exit(0)
+	}
+
+	prov.P.InitLogging()
+
+	// The upstream provider logs through the log package, which the bridge redirects to the engine
+	// while serving a request and leaves on stderr otherwise. The bridge logs through glog.
+	log.SetOutput(redact.NewWriter(log.Writer(), redactor))
+	logging.AddGlobalFilter(redactor)
+	middlewares = append([]Middleware{filterAttach(redactor.String)}, middlewares...)
+
+	err = provider.Main(pkg, func(host *provider.HostClient) (pulumirpc.ResourceProviderServer, error) {
+		if host != nil {
+			addr, err := serveEngine(flag.Args()[0], redactor.String)
+			if err != nil {
+				return nil, err
+			}
+			if host, err = provider.NewHostClient(addr); err != nil {
+				return nil, err
+			}
+		}
+		var srv pulumirpc.ResourceProviderServer = tfbridge.NewProvider(
+			context.TODO(), host, pkg, version, prov.P, prov, pulumiSchema)
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			srv = middlewares[i](host, srv)
+		}
+		return srv, nil
+	})
+	if err != nil {
+		cmdutil.ExitError(err.Error())
+	}
+}
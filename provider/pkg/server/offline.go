@@ -0,0 +1,205 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/pulumi/pulumi/pkg/v3/resource/provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Offline lets previews run while the NSX manager cannot be reached. unreachable reports why the
+// manager was found unreachable when the provider was configured, or nil if it was reached.
+//
+// While offline, data sources return unknown outputs and reads hand back the recorded state.
+// Anything that would change NSX outside of a preview fails, so `pulumi up` still needs the
+// manager. Invokes and reads carry no preview flag: once a change outside of a preview was
+// requested, they fail too.
+func Offline(unreachable func() error, pulumiSchema []byte) Middleware {
+	outputs, err := functionOutputs(pulumiSchema)
+	return func(host *provider.HostClient, next pulumirpc.ResourceProviderServer) pulumirpc.ResourceProviderServer {
+		return &offlineServer{
+			ResourceProviderServer: next,
+			host:                   host,
+			unreachable:            unreachable,
+			outputs:                outputs,
+			schemaErr:              err,
+		}
+	}
+}
+
+type offlineServer struct {
+	pulumirpc.ResourceProviderServer
+
+	host        *provider.HostClient
+	unreachable func() error
+	outputs     map[string][]string // function token -> output property names
+	schemaErr   error
+
+	// updating is set once a change outside of a preview was requested.
+	updating atomic.Bool
+}
+
+func (s *offlineServer) Configure(ctx context.Context,
+	req *pulumirpc.ConfigureRequest) (*pulumirpc.ConfigureResponse, error) {
+	resp, err := s.ResourceProviderServer.Configure(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if reason := s.unreachable(); reason != nil {
+		msg := fmt.Sprintf("%v. offlinePreview is enabled: data sources return unknown values and reads "+
+			"are skipped. Only `pulumi preview` can succeed until the manager is reachable.", reason)
+		if err := s.host.Log(ctx, diag.Warning, "", msg); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (s *offlineServer) Invoke(ctx context.Context, req *pulumirpc.InvokeRequest) (*pulumirpc.InvokeResponse, error) {
+	if s.unreachable() == nil {
+		return s.ResourceProviderServer.Invoke(ctx, req)
+	}
+	if err := s.requirePreview(); err != nil {
+		return nil, fmt.Errorf("%s: %w", req.GetTok(), err)
+	}
+	if s.schemaErr != nil {
+		return nil, s.schemaErr
+	}
+	names, ok := s.outputs[req.GetTok()]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", req.GetTok())
+	}
+
+	label := fmt.Sprintf("Invoke(%s)", req.GetTok())
+	args, err := plugin.UnmarshalProperties(req.GetArgs(), plugin.MarshalOptions{
+		Label: label + ".args", KeepUnknowns: true, SkipNulls: true})
+	if err != nil {
+		return nil, err
+	}
+
+	// Echo the arguments back, they are the only outputs whose value is known.
+	ret := resource.PropertyMap{}
+	for _, name := range names {
+		key := resource.PropertyKey(name)
+		if v, ok := args[key]; ok {
+			ret[key] = v
+			continue
+		}
+		ret[key] = resource.MakeComputed(resource.NewStringProperty(""))
+	}
+	mret, err := plugin.MarshalProperties(ret, plugin.MarshalOptions{
+		Label: label + ".returns", KeepUnknowns: true})
+	if err != nil {
+		return nil, err
+	}
+	msg := fmt.Sprintf("NSX manager unreachable, %s returns unknown values (offlinePreview)", req.GetTok())
+	if err := s.host.Log(ctx, diag.Warning, "", msg); err != nil {
+		return nil, err
+	}
+	return &pulumirpc.InvokeResponse{Return: mret}, nil
+}
+
+func (s *offlineServer) Read(ctx context.Context, req *pulumirpc.ReadRequest) (*pulumirpc.ReadResponse, error) {
+	if s.unreachable() == nil {
+		return s.ResourceProviderServer.Read(ctx, req)
+	}
+	if err := s.requirePreview(); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", req.GetUrn(), err)
+	}
+	msg := "NSX manager unreachable, keeping the recorded state (offlinePreview)"
+	if err := s.host.Log(ctx, diag.Warning, resource.URN(req.GetUrn()), msg); err != nil {
+		return nil, err
+	}
+	return &pulumirpc.ReadResponse{
+		Id:         req.GetId(),
+		Properties: req.GetProperties(),
+		Inputs:     req.GetInputs(),
+	}, nil
+}
+
+func (s *offlineServer) Create(ctx context.Context, req *pulumirpc.CreateRequest) (*pulumirpc.CreateResponse, error) {
+	if err := s.requireManager(req.GetPreview()); err != nil {
+		return nil, err
+	}
+	return s.ResourceProviderServer.Create(ctx, req)
+}
+
+func (s *offlineServer) Update(ctx context.Context, req *pulumirpc.UpdateRequest) (*pulumirpc.UpdateResponse, error) {
+	if err := s.requireManager(req.GetPreview()); err != nil {
+		return nil, err
+	}
+	return s.ResourceProviderServer.Update(ctx, req)
+}
+
+func (s *offlineServer) Delete(ctx context.Context, req *pulumirpc.DeleteRequest) (*emptypb.Empty, error) {
+	if err := s.requireManager(false); err != nil {
+		return nil, err
+	}
+	return s.ResourceProviderServer.Delete(ctx, req)
+}
+
+// requireManager fails operations that need the manager while it is unreachable. Previews of
+// creates and updates never reach NSX and are let through.
+func (s *offlineServer) requireManager(preview bool) error {
+	if preview {
+		return nil
+	}
+	s.updating.Store(true)
+	if reason := s.unreachable(); reason != nil {
+		return fmt.Errorf("%w: offlinePreview only applies to previews", reason)
+	}
+	return nil
+}
+
+// requirePreview fails the invokes and reads of an update while the manager is unreachable.
+func (s *offlineServer) requirePreview() error {
+	if s.updating.Load() {
+		return fmt.Errorf("%w: offlinePreview only applies to previews", s.unreachable())
+	}
+	return nil
+}
+
+// functionOutputs extracts the output property names of every function in the package schema.
+func functionOutputs(pulumiSchema []byte) (map[string][]string, error) {
+	var spec struct {
+		Functions map[string]struct {
+			Outputs struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"outputs"`
+		} `json:"functions"`
+	}
+	if err := json.Unmarshal(pulumiSchema, &spec); err != nil {
+		return nil, fmt.Errorf("cannot read package schema: %w", err)
+	}
+
+	outputs := make(map[string][]string, len(spec.Functions))
+	for tok, fn := range spec.Functions {
+		names := make([]string, 0, len(fn.Outputs.Properties))
+		for name := range fn.Outputs.Properties {
+			names = append(names, name)
+		}
+		outputs[tok] = names
+	}
+	return outputs, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const getVMs = "nsxt:index/getPolicyVms:getPolicyVms"
+
+// onlineProvider stands for the bridge, answering invokes and reads as if NSX were reachable.
+type onlineProvider struct {
+	pulumirpc.UnimplementedResourceProviderServer
+}
+
+func (onlineProvider) Invoke(context.Context, *pulumirpc.InvokeRequest) (*pulumirpc.InvokeResponse, error) {
+	return &pulumirpc.InvokeResponse{Return: &structpb.Struct{}}, nil
+}
+
+func (onlineProvider) Read(context.Context, *pulumirpc.ReadRequest) (*pulumirpc.ReadResponse, error) {
+	return &pulumirpc.ReadResponse{Id: "read from NSX"}, nil
+}
+
+func (onlineProvider) Create(context.Context, *pulumirpc.CreateRequest) (*pulumirpc.CreateResponse, error) {
+	return &pulumirpc.CreateResponse{Id: "created"}, nil
+}
+
+func TestOffline(t *testing.T) {
+	const urn = "urn:pulumi:dev::nsx::nsxt:index/policyGroup:PolicyGroup::g"
+	schema := []byte(`{"functions":{"` + getVMs + `":{"outputs":{"properties":{"displayName":{},"items":{}}}}}}`)
+	args, err := plugin.MarshalProperties(resource.PropertyMap{"displayName": resource.NewStringProperty("web")},
+		plugin.MarshalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := structpb.NewStruct(map[string]interface{}{"displayName": "g"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		unreachable error
+		up          bool
+		wantErr     string
+		wantWarning string
+	}{
+		{name: "reachable"},
+		{name: "offline preview", unreachable: errors.New("no route"), wantWarning: "offlinePreview"},
+		{name: "offline up", unreachable: errors.New("no route"), up: true, wantErr: "only applies to previews"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, engine := testHost(t)
+			s := Offline(func() error { return tt.unreachable }, schema)(host, onlineProvider{})
+			ctx := context.Background()
+			if tt.up {
+				if _, err := s.Create(ctx, &pulumirpc.CreateRequest{Urn: urn}); err == nil {
+					t.Fatal("Create() outside of a preview succeeded while offline")
+				}
+			} else if _, err := s.Create(ctx, &pulumirpc.CreateRequest{Urn: urn, Preview: true}); err != nil {
+				t.Fatal(err)
+			}
+
+			t.Run("invoke", func(t *testing.T) {
+				resp, err := s.Invoke(ctx, &pulumirpc.InvokeRequest{Tok: getVMs, Args: args})
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("Invoke() error = %v, want %q", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				ret, err := plugin.UnmarshalProperties(resp.GetReturn(), plugin.MarshalOptions{KeepUnknowns: true})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.unreachable == nil {
+					if len(ret) != 0 {
+						t.Errorf("Invoke() = %v, want the answer of the provider", ret)
+					}
+					return
+				}
+				if !ret["items"].IsComputed() || ret["displayName"].StringValue() != "web" {
+					t.Errorf("Invoke() = %v, want unknown items and the arguments echoed", ret)
+				}
+			})
+
+			t.Run("read", func(t *testing.T) {
+				resp, err := s.Read(ctx, &pulumirpc.ReadRequest{Id: "g", Urn: urn, Properties: state})
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("Read() error = %v, want %q", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := "read from NSX"
+				if tt.unreachable != nil {
+					want = "g"
+					if resp.GetProperties().AsMap()["displayName"] != "g" {
+						t.Errorf("Read() = %v, want the recorded state", resp.GetProperties())
+					}
+				}
+				if resp.GetId() != want {
+					t.Errorf("Read() ID = %q, want %q", resp.GetId(), want)
+				}
+			})
+
+			var warned bool
+			for _, msg := range engine.logged() {
+				warned = warned || strings.Contains(msg, "offlinePreview")
+			}
+			if warned != (tt.wantWarning != "") {
+				t.Errorf("warnings = %q, want a warning: %v", engine.logged(), tt.wantWarning != "")
+			}
+		})
+	}
+}
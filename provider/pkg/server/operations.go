@@ -0,0 +1,120 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/pulumi/pulumi/pkg/v3/resource/provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+// throttlingThreshold is the time the requests must have waited for the NSX API limits before a
+// warning is shown.
+const throttlingThreshold = time.Second
+
+// Track passes the resource operations and data source invocations on in the request context, so
+// that the API requests made with it are attributed to them by ops. The first time an operation
+// ends with the deployment noticeably slowed down by the NSX API limits, a single warning sums up
+// the throttling so far; a provider instance serves one deployment.
+func Track(ops *nsxapi.Operations) Middleware {
+	return func(host *provider.HostClient, next pulumirpc.ResourceProviderServer) pulumirpc.ResourceProviderServer {
+		return &trackServer{ResourceProviderServer: next, host: host, ops: ops}
+	}
+}
+
+type trackServer struct {
+	pulumirpc.ResourceProviderServer
+
+	host *provider.HostClient
+	ops  *nsxapi.Operations
+	// warned is set once throttling was reported.
+	warned atomic.Bool
+}
+
+func (s *trackServer) Create(ctx context.Context, req *pulumirpc.CreateRequest) (*pulumirpc.CreateResponse, error) {
+	if !req.GetPreview() {
+		var end func()
+		ctx, end = s.begin(ctx, "create", req.GetUrn(), "")
+		defer end()
+	}
+	return s.ResourceProviderServer.Create(ctx, req)
+}
+
+func (s *trackServer) Read(ctx context.Context, req *pulumirpc.ReadRequest) (*pulumirpc.ReadResponse, error) {
+	ctx, end := s.begin(ctx, "read", req.GetUrn(), req.GetId())
+	defer end()
+	return s.ResourceProviderServer.Read(ctx, req)
+}
+
+func (s *trackServer) Update(ctx context.Context, req *pulumirpc.UpdateRequest) (*pulumirpc.UpdateResponse, error) {
+	if !req.GetPreview() {
+		var end func()
+		ctx, end = s.begin(ctx, "update", req.GetUrn(), req.GetId())
+		defer end()
+	}
+	return s.ResourceProviderServer.Update(ctx, req)
+}
+
+func (s *trackServer) Delete(ctx context.Context, req *pulumirpc.DeleteRequest) (*emptypb.Empty, error) {
+	ctx, end := s.begin(ctx, "delete", req.GetUrn(), req.GetId())
+	defer end()
+	return s.ResourceProviderServer.Delete(ctx, req)
+}
+
+func (s *trackServer) Invoke(ctx context.Context, req *pulumirpc.InvokeRequest) (*pulumirpc.InvokeResponse, error) {
+	ctx, end := s.track(ctx, &nsxapi.Operation{
+		Type:    req.GetTok(),
+		Kind:    "invoke",
+		Context: ctx,
+	})
+	defer end()
+	return s.ResourceProviderServer.Invoke(ctx, req)
+}
+
+func (s *trackServer) begin(ctx context.Context, kind, urn, id string) (context.Context, func()) {
+	return s.track(ctx, &nsxapi.Operation{
+		URN:     urn,
+		Type:    string(resource.URN(urn).Type()),
+		Kind:    kind,
+		ID:      id,
+		Context: ctx,
+	})
+}
+
+// track returns the context of the request carrying op, and a function to call once op is over.
+func (s *trackServer) track(ctx context.Context, op *nsxapi.Operation) (context.Context, func()) {
+	return nsxapi.WithOperation(ctx, op), func() { s.reportThrottling(ctx) }
+}
+
+// reportThrottling warns, once, about the time spent waiting for the NSX API limits.
+func (s *trackServer) reportThrottling(ctx context.Context) {
+	waited, retries := s.ops.Throttling()
+	if s.host == nil || (waited < throttlingThreshold && retries == 0) || !s.warned.CompareAndSwap(false, true) {
+		return
+	}
+	msg := fmt.Sprintf("NSX API throttling is slowing this deployment down: %s spent waiting for the "+
+		"maxConcurrentRequests/requestsPerSecond limits so far, %d request(s) retried after HTTP 429/503",
+		waited.Round(100*time.Millisecond), retries)
+	_ = s.host.Log(ctx, diag.Warning, "", msg)
+}
@@ -0,0 +1,82 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+// throttledProvider sends a request throttled by NSX for every resource it creates.
+type throttledProvider struct {
+	pulumirpc.UnimplementedResourceProviderServer
+
+	client *http.Client
+	url    string
+}
+
+func (p throttledProvider) Create(context.Context, *pulumirpc.CreateRequest) (*pulumirpc.CreateResponse, error) {
+	resp, err := p.client.Get(p.url)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &pulumirpc.CreateResponse{Id: "created"}, nil
+}
+
+func TestTrackWarnsOnceAboutThrottling(t *testing.T) {
+	var sent int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every other request is asked to slow down.
+		if atomic.AddInt32(&sent, 1)%2 == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	ops := nsxapi.NewOperations()
+	client := &http.Client{Transport: nsxapi.Chain(http.DefaultTransport,
+		nsxapi.Throttle(nsxapi.Limits{MaxConcurrentRequests: 1}, ops))}
+
+	host, engine := testHost(t)
+	s := Track(ops)(host, throttledProvider{client: client, url: srv.URL})
+	for _, name := range []string{"a", "b", "c"} {
+		urn := "urn:pulumi:dev::nsx::nsxt:index/policyGroup:PolicyGroup::" + name
+		if _, err := s.Create(context.Background(), &pulumirpc.CreateRequest{Urn: urn}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var warnings []string
+	for _, msg := range engine.logged() {
+		if strings.Contains(msg, "throttling") {
+			warnings = append(warnings, msg)
+		}
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "1 request(s) retried") {
+		t.Errorf("warnings = %q, want one about the first retry", warnings)
+	}
+	if _, retries := ops.Throttling(); retries != 3 {
+		t.Errorf("Throttling() retries = %d, want 3", retries)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pulumi/pulumi/pkg/v3/resource/provider"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ReadOnly rejects creates, updates and deletes while readOnly reports true, before the bridge
+// gets a chance to send anything to NSX. Previews are still served.
+func ReadOnly(readOnly func() bool) Middleware {
+	return func(_ *provider.HostClient, next pulumirpc.ResourceProviderServer) pulumirpc.ResourceProviderServer {
+		return &readOnlyServer{ResourceProviderServer: next, readOnly: readOnly}
+	}
+}
+
+type readOnlyServer struct {
+	pulumirpc.ResourceProviderServer
+
+	readOnly func() bool
+}
+
+func (s *readOnlyServer) Create(ctx context.Context, req *pulumirpc.CreateRequest) (*pulumirpc.CreateResponse, error) {
+	if err := s.refuse("create", req.GetUrn(), req.GetPreview()); err != nil {
+		return nil, err
+	}
+	return s.ResourceProviderServer.Create(ctx, req)
+}
+
+func (s *readOnlyServer) Update(ctx context.Context, req *pulumirpc.UpdateRequest) (*pulumirpc.UpdateResponse, error) {
+	if err := s.refuse("update", req.GetUrn(), req.GetPreview()); err != nil {
+		return nil, err
+	}
+	return s.ResourceProviderServer.Update(ctx, req)
+}
+
+func (s *readOnlyServer) Delete(ctx context.Context, req *pulumirpc.DeleteRequest) (*emptypb.Empty, error) {
+	if err := s.refuse("delete", req.GetUrn(), false); err != nil {
+		return nil, err
+	}
+	return s.ResourceProviderServer.Delete(ctx, req)
+}
+
+func (s *readOnlyServer) refuse(op, urn string, preview bool) error {
+	if preview || !s.readOnly() {
+		return nil
+	}
+	return fmt.Errorf("refusing to %s %s: the provider is configured with readOnly", op, urn)
+}
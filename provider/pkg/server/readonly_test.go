@@ -0,0 +1,86 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"golang.org/x/exp/slices"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// changingProvider stands for the bridge, recording the changes that reach it.
+type changingProvider struct {
+	pulumirpc.UnimplementedResourceProviderServer
+
+	changes []string
+}
+
+func (p *changingProvider) Create(context.Context, *pulumirpc.CreateRequest) (*pulumirpc.CreateResponse, error) {
+	p.changes = append(p.changes, "create")
+	return &pulumirpc.CreateResponse{}, nil
+}
+
+func (p *changingProvider) Update(context.Context, *pulumirpc.UpdateRequest) (*pulumirpc.UpdateResponse, error) {
+	p.changes = append(p.changes, "update")
+	return &pulumirpc.UpdateResponse{}, nil
+}
+
+func (p *changingProvider) Delete(context.Context, *pulumirpc.DeleteRequest) (*emptypb.Empty, error) {
+	p.changes = append(p.changes, "delete")
+	return &emptypb.Empty{}, nil
+}
+
+func TestReadOnly(t *testing.T) {
+	const urn = "urn:pulumi:dev::nsx::nsxt:index/policyGroup:PolicyGroup::g"
+	tests := []struct {
+		name     string
+		readOnly bool
+		preview  bool
+		want     []string
+	}{
+		{name: "writable", want: []string{"create", "update", "delete"}},
+		{name: "read-only", readOnly: true, want: nil},
+		// Deletes carry no preview flag: they are only sent for real.
+		{name: "read-only preview", readOnly: true, preview: true, want: []string{"create", "update"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &changingProvider{}
+			s := ReadOnly(func() bool { return tt.readOnly })(nil, next)
+			ctx := context.Background()
+
+			_, createErr := s.Create(ctx, &pulumirpc.CreateRequest{Urn: urn, Preview: tt.preview})
+			_, updateErr := s.Update(ctx, &pulumirpc.UpdateRequest{Urn: urn, Preview: tt.preview})
+			_, deleteErr := s.Delete(ctx, &pulumirpc.DeleteRequest{Urn: urn})
+
+			if strings.Join(next.changes, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("changes = %v, want %v", next.changes, tt.want)
+			}
+			for op, err := range map[string]error{"create": createErr, "update": updateErr, "delete": deleteErr} {
+				refused := !slices.Contains(tt.want, op)
+				switch {
+				case refused && (err == nil || !strings.Contains(err.Error(), "refusing to "+op+" "+urn)):
+					t.Errorf("%s error = %v, want a refusal", op, err)
+				case !refused && err != nil:
+					t.Errorf("%s error = %v", op, err)
+				}
+			}
+		})
+	}
+}
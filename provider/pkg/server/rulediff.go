@@ -0,0 +1,376 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi/pkg/v3/resource/provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// rulesKey is the property holding the rules of a policy.
+const rulesKey = "rules"
+
+// RuleDiff diffs the rules of the resources of the given types rule by rule, instead of by list
+// index as the bridge does. Rules are matched by nsxId, else by display name, so that inserting a
+// rule shows up as a single added rule rather than as every following rule being changed. The
+// detailed diff reports the rules added, removed, moved and modified, and a message sums them up
+// by name. A rule whose sequence number alone changed is reported as moved when it changes
+// places, and as renumbered otherwise.
+func RuleDiff(types ...string) Middleware {
+	set := make(map[string]bool, len(types))
+	for _, t := range types {
+		set[t] = true
+	}
+	return func(host *provider.HostClient, next pulumirpc.ResourceProviderServer) pulumirpc.ResourceProviderServer {
+		return &ruleDiffServer{ResourceProviderServer: next, host: host, types: set}
+	}
+}
+
+type ruleDiffServer struct {
+	pulumirpc.ResourceProviderServer
+
+	host  *provider.HostClient
+	types map[string]bool
+}
+
+func (s *ruleDiffServer) Diff(ctx context.Context, req *pulumirpc.DiffRequest) (*pulumirpc.DiffResponse, error) {
+	resp, err := s.ResourceProviderServer.Diff(ctx, req)
+	urn := resource.URN(req.GetUrn())
+	if err != nil || !s.types[string(urn.Type())] || !hasRuleDiffs(resp) {
+		return resp, err
+	}
+	// Inputs are compared with inputs: the recorded outputs carry computed fields (path, revision,
+	// generated nsxId) the inputs don't have.
+	olds, ok := readRules(req.GetOldInputs())
+	if !ok {
+		return resp, nil
+	}
+	news, ok := readRules(req.GetNews())
+	if !ok {
+		return resp, nil
+	}
+
+	changes := diffRules(olds, news)
+	for path := range resp.DetailedDiff {
+		if isRulePath(path) {
+			delete(resp.DetailedDiff, path)
+		}
+	}
+	for path, kind := range changes.paths() {
+		resp.DetailedDiff[path] = &pulumirpc.PropertyDiff{Kind: kind, InputDiff: true}
+	}
+	if summary := changes.String(); summary != "" && s.host != nil {
+		_ = s.host.Log(ctx, diag.Info, urn, summary)
+	}
+	return resp, nil
+}
+
+// hasRuleDiffs tells whether the bridge reported changes to the rules, none of which forces a
+// replacement.
+func hasRuleDiffs(resp *pulumirpc.DiffResponse) bool {
+	found := false
+	for path, d := range resp.GetDetailedDiff() {
+		if !isRulePath(path) {
+			continue
+		}
+		switch d.GetKind() {
+		case pulumirpc.PropertyDiff_ADD_REPLACE, pulumirpc.PropertyDiff_DELETE_REPLACE,
+			pulumirpc.PropertyDiff_UPDATE_REPLACE:
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+func isRulePath(path string) bool {
+	return path == rulesKey || strings.HasPrefix(path, rulesKey+"[") || strings.HasPrefix(path, rulesKey+".")
+}
+
+// rule is a rule of a policy, as given in its inputs.
+type rule struct {
+	index    int // in the list
+	nsxID    string
+	name     string
+	sequence *float64
+	fields   resource.PropertyMap
+}
+
+func (r *rule) String() string {
+	return fmt.Sprintf("%q", r.name)
+}
+
+// readRules reads the rules of a policy. ok is false when they can't be told apart, because the
+// inputs aren't recorded or some nsxId or display name isn't known yet.
+func readRules(props *structpb.Struct) (rules []*rule, ok bool) {
+	if props == nil {
+		return nil, false
+	}
+	inputs, err := plugin.UnmarshalProperties(props, plugin.MarshalOptions{
+		KeepUnknowns: true, KeepSecrets: true, SkipNulls: true})
+	if err != nil {
+		return nil, false
+	}
+	list := unwrapSecret(inputs[rulesKey])
+	switch {
+	case list.IsNull():
+		return nil, true
+	case !list.IsArray():
+		return nil, false
+	}
+	for i, item := range list.ArrayValue() {
+		if item = unwrapSecret(item); !item.IsObject() {
+			return nil, false
+		}
+		r := &rule{index: i, fields: item.ObjectValue()}
+		if r.nsxID, ok = knownString(r.fields["nsxId"]); !ok {
+			return nil, false
+		}
+		if r.name, ok = knownString(r.fields["displayName"]); !ok || r.nsxID == "" && r.name == "" {
+			return nil, false
+		}
+		if v := unwrapSecret(r.fields["sequenceNumber"]); v.IsNumber() {
+			n := v.NumberValue()
+			r.sequence = &n
+		}
+		rules = append(rules, r)
+	}
+	return rules, true
+}
+
+func unwrapSecret(v resource.PropertyValue) resource.PropertyValue {
+	for v.IsSecret() {
+		v = v.SecretValue().Element
+	}
+	return v
+}
+
+// knownString reads an optional string, ok is false when its value isn't known yet.
+func knownString(v resource.PropertyValue) (s string, ok bool) {
+	v = unwrapSecret(v)
+	switch {
+	case v.ContainsUnknowns():
+		return "", false
+	case v.IsString():
+		return v.StringValue(), true
+	}
+	return "", true
+}
+
+// evaluationOrder returns rules in the order NSX evaluates them: by sequence number, or in list
+// order when some rule has none.
+func evaluationOrder(rules []*rule) []*rule {
+	ordered := append([]*rule(nil), rules...)
+	for _, r := range rules {
+		if r.sequence == nil {
+			return ordered
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return *ordered[i].sequence < *ordered[j].sequence })
+	return ordered
+}
+
+// ruleChange is the change to a rule found in both the old and new inputs.
+type ruleChange struct {
+	old, new   *rule
+	from, to   int      // positions in evaluation order
+	moved      bool     // the rule changed places relative to the others
+	renumbered bool     // its sequence number changed
+	modified   []string // other fields changed
+}
+
+type ruleChanges struct {
+	added   []ruleChange // with new and to only
+	removed []*rule
+	changed []ruleChange
+}
+
+// diffRules matches the old and new rules and tells what changed about each of them.
+func diffRules(olds, news []*rule) ruleChanges {
+	var changes ruleChanges
+
+	// Match by nsxId when the new rule has one, by display name otherwise. Duplicates are matched
+	// in order.
+	matched := map[*rule]*rule{} // new -> old
+	taken := map[*rule]bool{}
+	for _, n := range news {
+		for _, o := range olds {
+			if taken[o] || n.nsxID != "" && o.nsxID != n.nsxID || n.nsxID == "" && o.name != n.name {
+				continue
+			}
+			matched[n], taken[o] = o, true
+			break
+		}
+	}
+	for _, o := range olds {
+		if !taken[o] {
+			changes.removed = append(changes.removed, o)
+		}
+	}
+
+	oldOrder, newOrder := evaluationOrder(olds), evaluationOrder(news)
+	oldPosition := make(map[*rule]int, len(oldOrder))
+	for i, o := range oldOrder {
+		oldPosition[o] = i
+	}
+
+	// The rules kept in place are the longest run of matched rules whose old positions increase,
+	// in new evaluation order. Every other matched rule moved.
+	var kept []ruleChange
+	for to, n := range newOrder {
+		o, ok := matched[n]
+		if !ok {
+			changes.added = append(changes.added, ruleChange{new: n, to: to})
+			continue
+		}
+		kept = append(kept, ruleChange{old: o, new: n, from: oldPosition[o], to: to})
+	}
+	inPlace := longestIncreasing(kept)
+	for i, c := range kept {
+		c.moved = !inPlace[i]
+		c.renumbered = !sameSequence(c.old.sequence, c.new.sequence)
+		c.modified = changedFields(c.old.fields, c.new.fields)
+		if c.moved || c.renumbered || len(c.modified) > 0 {
+			changes.changed = append(changes.changed, c)
+		}
+	}
+	return changes
+}
+
+// longestIncreasing marks the changes forming the longest subsequence of increasing old
+// positions.
+func longestIncreasing(changes []ruleChange) []bool {
+	// tails[k] is the index of the smallest last element of an increasing subsequence of length
+	// k+1, and previous links each element to the one before it in its subsequence.
+	var tails []int
+	previous := make([]int, len(changes))
+	for i, c := range changes {
+		k := sort.Search(len(tails), func(k int) bool { return changes[tails[k]].from >= c.from })
+		previous[i] = -1
+		if k > 0 {
+			previous[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	in := make([]bool, len(changes))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+			in[i] = true
+		}
+	}
+	return in
+}
+
+func sameSequence(a, b *float64) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// changedFields lists the fields, other than the sequence number, whose values differ.
+func changedFields(old, new resource.PropertyMap) []string {
+	keys := map[resource.PropertyKey]bool{}
+	for k := range old {
+		keys[k] = true
+	}
+	for k := range new {
+		keys[k] = true
+	}
+	var fields []string
+	for k := range keys {
+		if k == "sequenceNumber" || strings.HasPrefix(string(k), "__") {
+			continue
+		}
+		if !old[k].DeepEquals(new[k]) {
+			fields = append(fields, string(k))
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// paths returns the detailed diff of the rules. Paths index the lists of inputs: added rules show
+// at their new index and removed ones at their old index. A moved rule shows as updated at its new
+// index, and any other changed rule by its changed fields, sequence number included, at its new
+// index.
+func (c ruleChanges) paths() map[string]pulumirpc.PropertyDiff_Kind {
+	paths := map[string]pulumirpc.PropertyDiff_Kind{}
+	at := func(index int) string { return fmt.Sprintf("%s[%d]", rulesKey, index) }
+	for _, ch := range c.added {
+		paths[at(ch.new.index)] = pulumirpc.PropertyDiff_ADD
+	}
+	for _, r := range c.removed {
+		paths[at(r.index)] = pulumirpc.PropertyDiff_DELETE
+	}
+	for _, ch := range c.changed {
+		if ch.moved {
+			// A rule taking the index of a removed one replaces its deletion with the update.
+			paths[at(ch.new.index)] = pulumirpc.PropertyDiff_UPDATE
+			continue
+		}
+		fields := append([]string(nil), ch.modified...)
+		if ch.renumbered {
+			fields = append(fields, "sequenceNumber")
+		}
+		for _, field := range fields {
+			paths[at(ch.new.index)+"."+field] = pulumirpc.PropertyDiff_UPDATE
+		}
+	}
+	return paths
+}
+
+// String sums the changes up by rule name, with positions in evaluation order.
+func (c ruleChanges) String() string {
+	var parts []string
+	for _, ch := range c.added {
+		parts = append(parts, fmt.Sprintf("%s added at position %d", ch.new, ch.to+1))
+	}
+	for _, r := range c.removed {
+		parts = append(parts, fmt.Sprintf("%s removed", r))
+	}
+	renumbered := 0
+	for _, ch := range c.changed {
+		var what []string
+		if ch.moved {
+			what = append(what, fmt.Sprintf("moved from position %d to %d", ch.from+1, ch.to+1))
+		}
+		if len(ch.modified) > 0 {
+			what = append(what, fmt.Sprintf("modified (%s)", strings.Join(ch.modified, ", ")))
+		}
+		if len(what) == 0 {
+			renumbered++
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", ch.new, strings.Join(what, " and ")))
+	}
+	if renumbered > 0 {
+		parts = append(parts, fmt.Sprintf("%d rule(s) renumbered in the same order", renumbered))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "Rule changes: " + strings.Join(parts, "; ")
+}
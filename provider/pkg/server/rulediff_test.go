@@ -0,0 +1,165 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+)
+
+// rulesOf builds rules from "name", "name:sequence" or "name:sequence:action" specs.
+func rulesOf(specs ...string) []*rule {
+	rules := make([]*rule, 0, len(specs))
+	for i, spec := range specs {
+		parts := append(strings.Split(spec, ":"), "", "")
+		r := &rule{index: i, name: parts[0], fields: resource.PropertyMap{
+			"displayName": resource.NewStringProperty(parts[0]),
+		}}
+		if parts[1] != "" {
+			sequence, _ := strconv.ParseFloat(parts[1], 64)
+			r.sequence = &sequence
+			r.fields["sequenceNumber"] = resource.NewNumberProperty(sequence)
+		}
+		if parts[2] != "" {
+			r.fields["action"] = resource.NewStringProperty(parts[2])
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+func TestDiffRules(t *testing.T) {
+	type kinds = map[string]pulumirpc.PropertyDiff_Kind
+	tests := []struct {
+		name       string
+		olds, news []*rule
+		want       kinds
+		summary    string
+	}{
+		{
+			name:    "insert at the top",
+			olds:    rulesOf("a:10", "b:20"),
+			news:    rulesOf("x:5", "a:10", "b:20"),
+			want:    kinds{"rules[0]": pulumirpc.PropertyDiff_ADD},
+			summary: `Rule changes: "x" added at position 1`,
+		},
+		{
+			name:    "remove",
+			olds:    rulesOf("a:10", "b:20", "c:30"),
+			news:    rulesOf("a:10", "c:30"),
+			want:    kinds{"rules[1]": pulumirpc.PropertyDiff_DELETE},
+			summary: `Rule changes: "b" removed`,
+		},
+		{
+			name:    "move by renumbering",
+			olds:    rulesOf("a:10", "b:20", "c:30"),
+			news:    rulesOf("a:10", "b:5", "c:30"),
+			want:    kinds{"rules[1]": pulumirpc.PropertyDiff_UPDATE},
+			summary: `Rule changes: "b" moved from position 2 to 1`,
+		},
+		{
+			name:    "move in the list",
+			olds:    rulesOf("a", "b", "c"),
+			news:    rulesOf("c", "a", "b"),
+			want:    kinds{"rules[0]": pulumirpc.PropertyDiff_UPDATE},
+			summary: `Rule changes: "c" moved from position 3 to 1`,
+		},
+		{
+			name: "renumber in place at a shifted index",
+			olds: rulesOf("a:10", "b:20"),
+			news: rulesOf("x:1", "a:11", "b:20"),
+			want: kinds{
+				"rules[0]":                pulumirpc.PropertyDiff_ADD,
+				"rules[1].sequenceNumber": pulumirpc.PropertyDiff_UPDATE,
+			},
+			summary: `Rule changes: "x" added at position 1; 1 rule(s) renumbered in the same order`,
+		},
+		{
+			name:    "modify",
+			olds:    rulesOf("a:10:ALLOW", "b:20:ALLOW"),
+			news:    rulesOf("a:10:ALLOW", "b:20:DROP"),
+			want:    kinds{"rules[1].action": pulumirpc.PropertyDiff_UPDATE},
+			summary: `Rule changes: "b" modified (action)`,
+		},
+		{
+			name:    "modify at a shifted index",
+			olds:    rulesOf("a:10:ALLOW", "b:20:ALLOW"),
+			news:    rulesOf("b:20:DROP"),
+			want:    kinds{"rules[0]": pulumirpc.PropertyDiff_DELETE, "rules[0].action": pulumirpc.PropertyDiff_UPDATE},
+			summary: `Rule changes: "a" removed; "b" modified (action)`,
+		},
+		{
+			name:    "duplicate names matched in order",
+			olds:    rulesOf("web", "web"),
+			news:    rulesOf("web", "web", "web"),
+			want:    kinds{"rules[2]": pulumirpc.PropertyDiff_ADD},
+			summary: `Rule changes: "web" added at position 3`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := diffRules(tt.olds, tt.news)
+			if got := changes.paths(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paths() = %v, want %v", got, tt.want)
+			}
+			if got := changes.String(); got != tt.summary {
+				t.Errorf("String() = %q, want %q", got, tt.summary)
+			}
+		})
+	}
+}
+
+func TestLongestIncreasing(t *testing.T) {
+	tests := []struct {
+		from []int
+		want []bool
+	}{
+		{from: nil, want: []bool{}},
+		{from: []int{0, 1, 2}, want: []bool{true, true, true}},
+		{from: []int{2, 0, 1}, want: []bool{false, true, true}},
+		{from: []int{1, 0}, want: []bool{false, true}},
+		{from: []int{0, 3, 1, 2}, want: []bool{true, false, true, true}},
+	}
+	for _, tt := range tests {
+		changes := make([]ruleChange, len(tt.from))
+		for i, from := range tt.from {
+			changes[i].from = from
+		}
+		if got := longestIncreasing(changes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("longestIncreasing(%v) = %v, want %v", tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestReadRulesWithoutKey(t *testing.T) {
+	props, err := plugin.MarshalProperties(resource.PropertyMap{
+		rulesKey: resource.NewArrayProperty([]resource.PropertyValue{
+			resource.NewObjectProperty(resource.PropertyMap{"nsxId": resource.NewStringProperty("r1")}),
+			resource.NewObjectProperty(resource.PropertyMap{"action": resource.NewStringProperty("DROP")}),
+		}),
+	}, plugin.MarshalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readRules(props); ok {
+		t.Error("readRules() told apart a rule without nsxId nor display name")
+	}
+}
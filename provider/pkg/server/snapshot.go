@@ -0,0 +1,229 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pulumi/pulumi/pkg/v3/resource/provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Drafts saves and restores distributed firewall drafts. nsxapi.Client implements it.
+type Drafts interface {
+	SaveDraft(ctx context.Context, id, description string) error
+	PublishDraft(ctx context.Context, id string) error
+	ListDrafts(ctx context.Context) ([]string, error)
+	DeleteDraft(ctx context.Context, id string) error
+}
+
+const (
+	// keptDrafts is the number of drafts kept per stack, the one just saved included.
+	keptDrafts = 3
+	// draftTime is the layout of the time in draft IDs, which sort by time.
+	draftTime = "20060102-150405"
+)
+
+// DFWSnapshot saves the distributed firewall to a draft before the first create, update or delete
+// of a resource of the given types, and publishes that draft back when one of them fails, so that
+// a deployment failing midway doesn't leave the firewall half-applied. drafts returns nil when
+// snapshots are disabled.
+//
+// The restore waits for the changes in flight, and the firewall changes that come after it are
+// refused: the deployment is over. The draft is named after the stack and the time it was saved, and
+// only the keptDrafts most recent drafts of the stack are kept: the older ones are deleted once the
+// new one is saved. The provider isn't told when a deployment ends, so the draft of the last
+// deployment can't be deleted when it succeeds; the kept drafts also allow a manual rollback.
+func DFWSnapshot(drafts func() Drafts, types ...string) Middleware {
+	set := make(map[string]bool, len(types))
+	for _, t := range types {
+		set[t] = true
+	}
+	return func(host *provider.HostClient, next pulumirpc.ResourceProviderServer) pulumirpc.ResourceProviderServer {
+		return &snapshotServer{
+			ResourceProviderServer: next,
+			host:                   host,
+			drafts:                 drafts,
+			types:                  set,
+			now:                    time.Now,
+		}
+	}
+}
+
+type snapshotServer struct {
+	pulumirpc.ResourceProviderServer
+
+	host   *provider.HostClient
+	drafts func() Drafts
+	types  map[string]bool
+	now    func() time.Time
+
+	// Changes hold mu for reading while they run, saves and restores hold it for writing.
+	mu       sync.RWMutex
+	draft    string // ID of the saved draft
+	restored error  // the failure the firewall was restored after
+}
+
+func (s *snapshotServer) Create(ctx context.Context, req *pulumirpc.CreateRequest) (*pulumirpc.CreateResponse, error) {
+	if req.GetPreview() {
+		return s.ResourceProviderServer.Create(ctx, req)
+	}
+	var resp *pulumirpc.CreateResponse
+	err := s.change(ctx, req.GetUrn(), func() (err error) {
+		resp, err = s.ResourceProviderServer.Create(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (s *snapshotServer) Update(ctx context.Context, req *pulumirpc.UpdateRequest) (*pulumirpc.UpdateResponse, error) {
+	if req.GetPreview() {
+		return s.ResourceProviderServer.Update(ctx, req)
+	}
+	var resp *pulumirpc.UpdateResponse
+	err := s.change(ctx, req.GetUrn(), func() (err error) {
+		resp, err = s.ResourceProviderServer.Update(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (s *snapshotServer) Delete(ctx context.Context, req *pulumirpc.DeleteRequest) (*emptypb.Empty, error) {
+	var resp *emptypb.Empty
+	err := s.change(ctx, req.GetUrn(), func() (err error) {
+		resp, err = s.ResourceProviderServer.Delete(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// change runs a change to the resource urn, with the firewall saved beforehand and restored if it
+// fails.
+func (s *snapshotServer) change(ctx context.Context, urn string, run func() error) error {
+	u := resource.URN(urn)
+	drafts := s.drafts()
+	if drafts == nil || !s.types[string(u.Type())] {
+		return run()
+	}
+
+	draft, err := s.begin(ctx, drafts, u)
+	if err != nil {
+		return err
+	}
+	err = run()
+	s.mu.RUnlock()
+	if err != nil {
+		return s.restore(ctx, drafts, draft, u, err)
+	}
+	return nil
+}
+
+// begin saves the firewall unless it already was, and returns the ID of the draft holding mu for
+// reading.
+func (s *snapshotServer) begin(ctx context.Context, drafts Drafts, u resource.URN) (string, error) {
+	for {
+		s.mu.RLock()
+		if s.restored != nil {
+			draft := s.draft
+			s.mu.RUnlock()
+			return "", fmt.Errorf("refusing to change %s: the distributed firewall was restored from draft "+
+				"%s after an earlier failure", u, draft)
+		}
+		if s.draft != "" {
+			return s.draft, nil
+		}
+		s.mu.RUnlock()
+
+		s.mu.Lock()
+		if s.draft == "" {
+			id := draftPrefix(u.Stack()) + s.now().UTC().Format(draftTime)
+			description := fmt.Sprintf("Distributed firewall saved before deploying stack %s", u.Stack())
+			if err := drafts.SaveDraft(ctx, id, description); err != nil {
+				s.mu.Unlock()
+				return "", fmt.Errorf("cannot save the distributed firewall to a draft before changing %s: %w",
+					u, err)
+			}
+			s.draft = id
+			s.prune(ctx, drafts, u)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// draftPrefix is the prefix of the IDs of the drafts saved for stack.
+func draftPrefix(stack tokens.QName) string {
+	return fmt.Sprintf("pulumi-%s-", stack)
+}
+
+// prune deletes the drafts of the stack of u but the keptDrafts most recent ones. Failures are
+// logged: the deployment goes on with the drafts it couldn't delete.
+func (s *snapshotServer) prune(ctx context.Context, drafts Drafts, u resource.URN) {
+	ids, err := drafts.ListDrafts(ctx)
+	if err == nil {
+		prefix := draftPrefix(u.Stack())
+		var saved []string
+		for _, id := range ids {
+			// The time check keeps the drafts of a stack named after this one with a suffix.
+			if t, ok := strings.CutPrefix(id, prefix); ok {
+				if _, parseErr := time.Parse(draftTime, t); parseErr == nil {
+					saved = append(saved, id)
+				}
+			}
+		}
+		sort.Strings(saved)
+		for i := 0; i < len(saved)-keptDrafts && err == nil; i++ {
+			err = drafts.DeleteDraft(ctx, saved[i])
+		}
+	}
+	if err != nil && s.host != nil {
+		_ = s.host.Log(ctx, diag.Warning, u, fmt.Sprintf("Cannot delete the distributed firewall drafts "+
+			"saved by earlier deployments: %v", err))
+	}
+}
+
+// restore publishes the draft after the change to u failed with err, unless it already was. It
+// waits for the other changes in flight. err is returned as is, keeping its gRPC status and the
+// partial state in its details; the outcome of the restore is logged.
+func (s *snapshotServer) restore(ctx context.Context, drafts Drafts, draft string, u resource.URN,
+	err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.restored != nil {
+		return err
+	}
+	s.restored = err
+	failure := status.Convert(err).Message()
+	severity, msg := diag.Warning, fmt.Sprintf("The change failed (%s). The distributed firewall was restored "+
+		"from draft %s, saved before the first change of this deployment. Run `pulumi refresh` to bring the "+
+		"stack state in line with NSX.", failure, draft)
+	if restoreErr := drafts.PublishDraft(ctx, draft); restoreErr != nil {
+		severity, msg = diag.Error, fmt.Sprintf("The change failed (%s), and restoring the distributed "+
+			"firewall from draft %s failed too: %v", failure, draft, restoreErr)
+	}
+	if s.host != nil {
+		_ = s.host.Log(ctx, severity, u, msg)
+	}
+	return err
+}
@@ -0,0 +1,222 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+// failingProvider fails the creation of resources named "fail" with a partial state in the
+// error details, as the bridge does.
+type failingProvider struct {
+	pulumirpc.UnimplementedResourceProviderServer
+}
+
+func (failingProvider) Create(_ context.Context, req *pulumirpc.CreateRequest) (*pulumirpc.CreateResponse, error) {
+	if strings.HasSuffix(req.GetUrn(), "::fail") {
+		st, err := status.New(codes.Unknown, "creating failed").WithDetails(&pulumirpc.ErrorResourceInitFailed{
+			Id:      "partial",
+			Reasons: []string{"creating failed"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, st.Err()
+	}
+	return &pulumirpc.CreateResponse{Id: "created"}, nil
+}
+
+// draftsServer is an NSX stand-in serving the drafts API, recording the calls made to it.
+type draftsServer struct {
+	mu         sync.Mutex
+	calls      []string
+	drafts     map[string]bool
+	publishErr bool
+}
+
+func (s *draftsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	call := r.Method + " " + strings.TrimPrefix(r.URL.Path, "/policy/api/v1")
+	if r.URL.RawQuery != "" {
+		call += "?" + r.URL.RawQuery
+	}
+	s.calls = append(s.calls, call)
+	id := strings.TrimPrefix(r.URL.Path, "/policy/api/v1/infra/drafts/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/policy/api/v1/infra/drafts":
+		var results []map[string]string
+		for id := range s.drafts {
+			results = append(results, map[string]string{"id": id})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	case r.Method == http.MethodGet && !s.drafts[id]:
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodPatch:
+		s.drafts[id] = true
+	case r.Method == http.MethodDelete:
+		delete(s.drafts, id)
+	case r.Method == http.MethodPost && s.publishErr:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func TestDFWSnapshot(t *testing.T) {
+	const (
+		policyType = "nsxt:index/policySecurityPolicy:PolicySecurityPolicy"
+		draft      = "pulumi-dev-20240102-030405"
+	)
+	urn := func(typ, name string) string { return "urn:pulumi:dev::nsx::" + typ + "::" + name }
+	tests := []struct {
+		name       string
+		drafts     map[string]bool
+		publishErr bool
+		wantCalls  []string
+		wantErr    string
+	}{
+		{
+			name: "restored",
+			wantCalls: []string{
+				"GET /infra/drafts/" + draft,
+				"PATCH /infra/drafts/" + draft,
+				"GET /infra/drafts?page_size=1000",
+				"POST /infra/drafts/" + draft + "?action=publish",
+			},
+		},
+		{
+			name:       "restore failed",
+			publishErr: true,
+			wantCalls: []string{
+				"GET /infra/drafts/" + draft,
+				"PATCH /infra/drafts/" + draft,
+				"GET /infra/drafts?page_size=1000",
+				"POST /infra/drafts/" + draft + "?action=publish",
+			},
+		},
+		{
+			name:      "draft exists",
+			drafts:    map[string]bool{draft: true},
+			wantCalls: []string{"GET /infra/drafts/" + draft},
+			wantErr:   "already exists",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nsx := &draftsServer{drafts: map[string]bool{}, publishErr: tt.publishErr}
+			for id := range tt.drafts {
+				nsx.drafts[id] = true
+			}
+			srv := httptest.NewServer(nsx)
+			t.Cleanup(srv.Close)
+			base, err := url.Parse(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := nsxapi.NewClient(base, http.DefaultTransport, nil)
+			s := DFWSnapshot(func() Drafts { return client }, policyType)(nil, failingProvider{}).(*snapshotServer)
+			s.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+			ctx := context.Background()
+
+			// Other resources don't save the firewall.
+			group := urn("nsxt:index/policyGroup:PolicyGroup", "g")
+			if _, err := s.Create(ctx, &pulumirpc.CreateRequest{Urn: group}); err != nil {
+				t.Fatal(err)
+			}
+			if len(nsx.calls) != 0 {
+				t.Fatalf("calls = %v, want none", nsx.calls)
+			}
+
+			_, err = s.Create(ctx, &pulumirpc.CreateRequest{Urn: urn(policyType, "ok")})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Create() error = %v, want %q", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(nsx.calls, tt.wantCalls) {
+					t.Errorf("calls = %v, want %v", nsx.calls, tt.wantCalls)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = s.Create(ctx, &pulumirpc.CreateRequest{Urn: urn(policyType, "fail")})
+			st := status.Convert(err)
+			if st.Message() != "creating failed" || len(st.Details()) != 1 {
+				t.Errorf("Create() error = %v with details %v, want the original error", err, st.Details())
+			}
+			if !reflect.DeepEqual(nsx.calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", nsx.calls, tt.wantCalls)
+			}
+
+			_, err = s.Create(ctx, &pulumirpc.CreateRequest{Urn: urn(policyType, "later")})
+			if err == nil || !strings.Contains(err.Error(), "restored from draft "+draft) {
+				t.Errorf("Create() after the restore error = %v, want a refusal", err)
+			}
+		})
+	}
+}
+
+func TestDFWSnapshotPrunesDrafts(t *testing.T) {
+	const policyType = "nsxt:index/policySecurityPolicy:PolicySecurityPolicy"
+	nsx := &draftsServer{drafts: map[string]bool{
+		"pulumi-dev-20240101-000000":    true,
+		"pulumi-dev-20240102-000000":    true,
+		"pulumi-dev-20240103-000000":    true,
+		"pulumi-dev-eu-20240101-000000": true, // another stack
+		"pulumi-dev-manual":             true, // not saved by the provider
+		"auto-draft-1":                  true,
+	}}
+	srv := httptest.NewServer(nsx)
+	t.Cleanup(srv.Close)
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := nsxapi.NewClient(base, http.DefaultTransport, nil)
+	s := DFWSnapshot(func() Drafts { return client }, policyType)(nil, failingProvider{}).(*snapshotServer)
+	s.now = func() time.Time { return time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC) }
+
+	urn := "urn:pulumi:dev::nsx::" + policyType + "::ok"
+	if _, err := s.Create(context.Background(), &pulumirpc.CreateRequest{Urn: urn}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{
+		"pulumi-dev-20240102-000000":    true,
+		"pulumi-dev-20240103-000000":    true,
+		"pulumi-dev-20240104-000000":    true,
+		"pulumi-dev-eu-20240101-000000": true,
+		"pulumi-dev-manual":             true,
+		"auto-draft-1":                  true,
+	}
+	if !reflect.DeepEqual(nsx.drafts, want) {
+		t.Errorf("drafts = %v, want %v", nsx.drafts, want)
+	}
+}
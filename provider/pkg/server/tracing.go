@@ -0,0 +1,108 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/pulumi/pulumi/pkg/v3/resource/provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/telemetry"
+)
+
+// flushTimeout bounds the time spent exporting the pending spans when the deployment is cancelled.
+const flushTimeout = 5 * time.Second
+
+// Trace wraps resource operations and data source invocations in spans, the parents of the spans
+// of the NSX requests they cause. flush is called when the engine cancels the deployment.
+func Trace(flush func(context.Context)) Middleware {
+	return func(_ *provider.HostClient, next pulumirpc.ResourceProviderServer) pulumirpc.ResourceProviderServer {
+		return &traceServer{ResourceProviderServer: next, flush: flush}
+	}
+}
+
+type traceServer struct {
+	pulumirpc.ResourceProviderServer
+
+	flush func(context.Context)
+}
+
+func (s *traceServer) Invoke(ctx context.Context,
+	req *pulumirpc.InvokeRequest) (resp *pulumirpc.InvokeResponse, err error) {
+	ctx, end := s.start(ctx, "invoke "+req.GetTok(), attribute.String("pulumi.function", req.GetTok()))
+	defer func() { end(err) }()
+	return s.ResourceProviderServer.Invoke(ctx, req)
+}
+
+func (s *traceServer) Create(ctx context.Context,
+	req *pulumirpc.CreateRequest) (resp *pulumirpc.CreateResponse, err error) {
+	ctx, end := s.startResource(ctx, "create", req.GetUrn(), attribute.Bool("pulumi.preview", req.GetPreview()))
+	defer func() { end(err) }()
+	return s.ResourceProviderServer.Create(ctx, req)
+}
+
+func (s *traceServer) Read(ctx context.Context, req *pulumirpc.ReadRequest) (resp *pulumirpc.ReadResponse, err error) {
+	ctx, end := s.startResource(ctx, "read", req.GetUrn(), attribute.String("pulumi.id", req.GetId()))
+	defer func() { end(err) }()
+	return s.ResourceProviderServer.Read(ctx, req)
+}
+
+func (s *traceServer) Update(ctx context.Context,
+	req *pulumirpc.UpdateRequest) (resp *pulumirpc.UpdateResponse, err error) {
+	ctx, end := s.startResource(ctx, "update", req.GetUrn(),
+		attribute.String("pulumi.id", req.GetId()), attribute.Bool("pulumi.preview", req.GetPreview()))
+	defer func() { end(err) }()
+	return s.ResourceProviderServer.Update(ctx, req)
+}
+
+func (s *traceServer) Delete(ctx context.Context, req *pulumirpc.DeleteRequest) (resp *emptypb.Empty, err error) {
+	ctx, end := s.startResource(ctx, "delete", req.GetUrn(), attribute.String("pulumi.id", req.GetId()))
+	defer func() { end(err) }()
+	return s.ResourceProviderServer.Delete(ctx, req)
+}
+
+func (s *traceServer) startResource(ctx context.Context, op, urn string,
+	attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	typ := string(resource.URN(urn).Type())
+	attrs = append(attrs, attribute.String("pulumi.urn", urn), attribute.String("pulumi.type", typ))
+	return s.start(ctx, op+" "+typ, attrs...)
+}
+
+func (s *traceServer) start(ctx context.Context, name string,
+	attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	ctx, span := telemetry.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func (s *traceServer) Cancel(ctx context.Context, req *emptypb.Empty) (*emptypb.Empty, error) {
+	flushCtx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	s.flush(flushCtx)
+	return s.ResourceProviderServer.Cancel(ctx, req)
+}
@@ -0,0 +1,127 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+// requestingProvider reads a segment from NSX, with the context of the request, for every resource
+// it creates.
+type requestingProvider struct {
+	pulumirpc.UnimplementedResourceProviderServer
+
+	client *http.Client
+	url    string
+}
+
+func (p requestingProvider) Create(ctx context.Context, _ *pulumirpc.CreateRequest) (*pulumirpc.CreateResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.url+"/policy/api/v1/infra/segments/web", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &pulumirpc.CreateResponse{Id: "web"}, nil
+}
+
+func (requestingProvider) Cancel(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func TestTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	var sent int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first request is asked to slow down, the retry finds nothing.
+		if atomic.AddInt32(&sent, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+	ops := nsxapi.NewOperations()
+	client := &http.Client{Transport: nsxapi.Chain(http.DefaultTransport,
+		nsxapi.Tracing(ops), nsxapi.Throttle(nsxapi.Limits{MaxConcurrentRequests: 1}, ops))}
+
+	var flushes int32
+	flush := func(context.Context) { atomic.AddInt32(&flushes, 1) }
+	s := Trace(flush)(nil, Track(ops)(nil, requestingProvider{client: client, url: srv.URL}))
+
+	const urn = "urn:pulumi:dev::nsx::nsxt:index/policySegment:PolicySegment::web"
+	if _, err := s.Create(context.Background(), &pulumirpc.CreateRequest{Urn: urn}); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&flushes); n != 0 {
+		t.Errorf("flushed %d times during the deployment, want none", n)
+	}
+	if _, err := s.Cancel(context.Background(), &emptypb.Empty{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&flushes); n != 1 {
+		t.Errorf("flushed %d times on cancel, want once", n)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2: %v", len(spans), spans)
+	}
+	call, op := spans[0], spans[1]
+	if op.Name != "create nsxt:index/policySegment:PolicySegment" || op.Parent.IsValid() {
+		t.Errorf("operation span %q with parent %v, want a root create span", op.Name, op.Parent)
+	}
+	if call.Name != "NSX GET" || call.Parent.SpanID() != op.SpanContext.SpanID() {
+		t.Errorf("call span %q with parent %v, want a child of the create span", call.Name, call.Parent.SpanID())
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range call.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	for key, want := range map[attribute.Key]attribute.Value{
+		"nsx.path":         attribute.StringValue("/infra/segments/web"),
+		"http.status_code": attribute.IntValue(http.StatusNotFound),
+		"nsx.retry_count":  attribute.IntValue(1),
+	} {
+		if got := attrs[key]; got != want {
+			t.Errorf("call span %s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+	if call.Status.Code != codes.Error {
+		t.Errorf("call span status = %v, want an error", call.Status)
+	}
+}
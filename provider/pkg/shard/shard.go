@@ -0,0 +1,202 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shard splits collections too large for a single NSX object into shards, keeping items
+// in the shard they were in so that a change to the collection changes few shards.
+package shard
+
+import (
+	"sort"
+)
+
+// Shard is a part of a collection. IDs are stable: a shard keeps its ID as long as it exists, and
+// new shards get IDs never used by the previous ones.
+type Shard struct {
+	ID    int
+	Items []string
+}
+
+// Set shards an unordered collection into shards of at most size items. Items stay in their
+// previous shard, new ones fill the shards with room, in ID order, then new shards. Emptied shards
+// are dropped. Items are sorted within each shard.
+func Set(previous []Shard, items []string, size int) []Shard {
+	wanted := map[string]bool{}
+	for _, item := range items {
+		wanted[item] = true
+	}
+
+	shards := make([]Shard, 0, len(previous))
+	placed := map[string]bool{}
+	for _, s := range previous {
+		kept := Shard{ID: s.ID}
+		for _, item := range s.Items {
+			if wanted[item] && !placed[item] && len(kept.Items) < size {
+				kept.Items = append(kept.Items, item)
+				placed[item] = true
+			}
+		}
+		shards = append(shards, kept)
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].ID < shards[j].ID })
+
+	var added []string
+	for item := range wanted {
+		if !placed[item] {
+			added = append(added, item)
+		}
+	}
+	sort.Strings(added)
+	next := nextID(previous)
+	for i := 0; len(added) > 0; i++ {
+		if i == len(shards) {
+			shards = append(shards, Shard{ID: next})
+			next++
+		}
+		room := size - len(shards[i].Items)
+		if room > len(added) {
+			room = len(added)
+		}
+		shards[i].Items = append(shards[i].Items, added[:room]...)
+		added = added[room:]
+	}
+
+	result := shards[:0]
+	for _, s := range shards {
+		if len(s.Items) > 0 {
+			sort.Strings(s.Items)
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// List shards an ordered collection into consecutive shards of at most size items, which keep
+// the order of items across shards. Items stay in their previous shard while this keeps the
+// order, other ones join the shard of the item before them. Overflowing shards are split, the
+// new parts getting new IDs, and emptied shards are dropped. Items must be unique.
+func List(previous []Shard, items []string, size int) []Shard {
+	position := map[int]int{}
+	of := map[string]int{}
+	for i, s := range previous {
+		position[s.ID] = i
+		for _, item := range s.Items {
+			of[item] = s.ID
+		}
+	}
+
+	// Assign items to shards in order, never going back to an earlier shard. New shards get
+	// their ID once the previous IDs are known to be taken, marked with -1 until then.
+	var shards []Shard
+	for _, item := range items {
+		id, ok := of[item]
+		switch {
+		case len(shards) == 0 && ok:
+			shards = append(shards, Shard{ID: id})
+		case len(shards) == 0:
+			// The first items go to the first shard.
+			first := -1
+			if len(previous) > 0 {
+				first = previous[0].ID
+			}
+			shards = append(shards, Shard{ID: first})
+		case ok && id != shards[len(shards)-1].ID && after(position, id, shards[len(shards)-1].ID):
+			shards = append(shards, Shard{ID: id})
+		}
+		last := &shards[len(shards)-1]
+		last.Items = append(last.Items, item)
+	}
+
+	next := nextID(previous)
+	var result []Shard
+	for _, s := range shards {
+		if s.ID == -1 {
+			s.ID = next
+			next++
+		}
+		for len(s.Items) > size {
+			result = append(result, Shard{ID: s.ID, Items: s.Items[:size]})
+			s = Shard{ID: next, Items: s.Items[size:]}
+			next++
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// after tells whether shard id comes after shard current, a new shard (-1) coming first.
+func after(position map[int]int, id, current int) bool {
+	return current == -1 || position[id] > position[current]
+}
+
+// nextID returns the ID following the highest one of shards.
+func nextID(shards []Shard) int {
+	next := 0
+	for _, s := range shards {
+		if s.ID >= next {
+			next = s.ID + 1
+		}
+	}
+	return next
+}
+
+// Number gives increasing numbers to ordered items, from first. As many items as possible keep
+// their previous number, the others take numbers evenly spread between them, or gap apart after
+// the last kept one, so that inserting or moving a few items renumbers only them while there is
+// room.
+func Number(previous map[string]int, items []string, first, gap int) map[string]int {
+	// fits tells whether items i and j (i < j) can keep numbers a and b with the items between
+	// them numbered in between. Item -1 stands for the start, numbered first-1.
+	fits := func(i, a, j, b int) bool { return b-a >= j-i }
+
+	// kept[j] is the most items up to j that can keep their number when j does, and from[j] the
+	// kept item before j, -1 for none.
+	kept := make([]int, len(items))
+	from := make([]int, len(items))
+	last := -1
+	for j, item := range items {
+		from[j] = -1
+		p, ok := previous[item]
+		if !ok || !fits(-1, first-1, j, p) {
+			continue
+		}
+		kept[j] = 1
+		for i := 0; i < j; i++ {
+			if kept[i] > 0 && kept[i]+1 > kept[j] && fits(i, previous[items[i]], j, p) {
+				kept[j], from[j] = kept[i]+1, i
+			}
+		}
+		if last == -1 || kept[j] >= kept[last] {
+			last = j
+		}
+	}
+	var keep []int
+	for j := last; j != -1; j = from[j] {
+		keep = append([]int{j}, keep...)
+	}
+
+	numbers := make(map[string]int, len(items))
+	i, n := -1, first-1
+	for _, j := range keep {
+		p := previous[items[j]]
+		for k := i + 1; k < j; k++ {
+			numbers[items[k]] = n + (k-i)*(p-n)/(j-i)
+		}
+		numbers[items[j]] = p
+		i, n = j, p
+	}
+	for k := i + 1; k < len(items); k++ {
+		numbers[items[k]] = n + (k-i)*gap
+	}
+	return numbers
+}
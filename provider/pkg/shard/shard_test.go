@@ -0,0 +1,94 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shard
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous map[string]int
+		items    []string
+		first    int
+		gap      int
+		want     map[string]int
+	}{
+		{
+			name:  "new",
+			items: []string{"a", "b", "c"},
+			first: 1,
+			gap:   10,
+			want:  map[string]int{"a": 10, "b": 20, "c": 30},
+		},
+		{
+			name:     "appended",
+			previous: map[string]int{"a": 10, "b": 20},
+			items:    []string{"a", "b", "c"},
+			first:    1,
+			gap:      10,
+			want:     map[string]int{"a": 10, "b": 20, "c": 30},
+		},
+		{
+			name:     "inserted",
+			previous: map[string]int{"a": 10, "b": 20, "c": 30},
+			items:    []string{"x", "a", "y", "z", "b", "c"},
+			first:    1,
+			gap:      10,
+			want:     map[string]int{"x": 5, "a": 10, "y": 13, "z": 16, "b": 20, "c": 30},
+		},
+		{
+			name:     "removed",
+			previous: map[string]int{"a": 10, "b": 20, "c": 30},
+			items:    []string{"a", "c"},
+			first:    1,
+			gap:      10,
+			want:     map[string]int{"a": 10, "c": 30},
+		},
+		{
+			name:     "moved",
+			previous: map[string]int{"a": 10, "b": 20, "c": 30, "d": 40},
+			items:    []string{"d", "a", "b", "c"},
+			first:    1,
+			gap:      10,
+			want:     map[string]int{"d": 5, "a": 10, "b": 20, "c": 30},
+		},
+		{
+			name:     "no room",
+			previous: map[string]int{"a": 0, "b": 1, "c": 2},
+			items:    []string{"a", "x", "b", "c"},
+			first:    0,
+			gap:      1,
+			want:     map[string]int{"a": 0, "x": 1, "b": 2, "c": 3},
+		},
+		{
+			name:     "before first",
+			previous: map[string]int{"a": 1, "b": 2},
+			items:    []string{"a", "b"},
+			first:    5,
+			gap:      1,
+			want:     map[string]int{"a": 5, "b": 6},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Number(tt.previous, tt.items, tt.first, tt.gap); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Number() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -15,7 +15,7 @@
 package nsxt
 
 import (
-	
+	"context"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -28,6 +28,8 @@ import (
 	"github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfbridge"
 	shimv2 "github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfshim/sdk-v2"
 	"github.com/vmware/terraform-provider-nsxt/nsxt"
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/native"
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
 	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/version"
 )
 
@@ -60,14 +62,39 @@ func makeResource(mod string, res string) tokens.Type {
 // It should validate that the provider can be configured, and provide actionable errors in the case
 // it cannot be. Configuration variables can be read from `vars` using the `stringValue` function -
 // for example `stringValue(vars, "accessKey")`.
-func preConfigureCallback(vars resource.PropertyMap, c shim.ResourceConfig) error {
-	return nil
+// Credentials kept out of the configuration are resolved here, and handed to the configure step
+// through conn so that they never reach the state.
+func preConfigureCallback(conn *nsxapi.Connection) tfbridge.PreConfigureCallback {
+	return func(vars resource.PropertyMap, c shim.ResourceConfig) error {
+		credentials, err := resolveCredentials(context.Background(), vars)
+		if err != nil {
+			return err
+		}
+		conn.SetCredentials(credentials)
+		return nil
+	}
 }
 
 // Provider returns additional overlaid schema and metadata associated with the provider..
 func Provider() tfbridge.ProviderInfo {
+	return newProvider(nsxapi.NewConnection())
+}
+
+// newProvider returns the provider metadata, with the upstream provider wired to conn.
+func newProvider(conn *nsxapi.Connection) tfbridge.ProviderInfo {
 	// Instantiate the Terraform provider
-	p := shimv2.NewProvider(nsxt.Provider())
+	upstream := nsxt.Provider()
+	extendProviderSchema(upstream.Schema)
+	for name, res := range native.Resources(conn) {
+		upstream.ResourcesMap[name] = res
+	}
+	for name, ds := range native.DataSources(conn) {
+		upstream.DataSourcesMap[name] = ds
+	}
+	native.IgnoreUnmanagedRules(upstream.ResourcesMap["nsxt_policy_security_policy"])
+	native.IgnoreUnmanagedRules(upstream.ResourcesMap["nsxt_policy_gateway_policy"])
+	wrapConfigure(upstream, configureProvider(conn, upstream.Schema))
+	p := shimv2.NewProvider(upstream)
 			// Create a Pulumi provider mapping
 	prov := tfbridge.ProviderInfo{
 		P:    p,
@@ -116,7 +143,7 @@ func Provider() tfbridge.ProviderInfo {
 			// 	},
 			// },
 		},
-		PreConfigureCallback: preConfigureCallback,
+		PreConfigureCallback: preConfigureCallback(conn),
 		Resources:            map[string]*tfbridge.ResourceInfo{
 			// Map each resource in the Terraform provider to a Pulumi type. Two examples
 			// are below - the single line form is the common case. The multi-line form is
@@ -261,6 +288,13 @@ func Provider() tfbridge.ProviderInfo {
 			"nsxt_failure_domain": {Tok: makeResource(mainMod, "nsxt_failure_domain")},
 			"nsxt_cluster_virtual_ip": {Tok: makeResource(mainMod, "nsxt_cluster_virtual_ip")},
 			"nsxt_policy_host_transport_node_profile": {Tok: makeResource(mainMod, "nsxt_policy_host_transport_node_profile")},
+			"nsxt_policy_object": {Tok: makeResource(mainMod, "nsxt_policy_object")},
+			"nsxt_policy_sharded_group": {Tok: makeResource(mainMod, "nsxt_policy_sharded_group")},
+			"nsxt_policy_sharded_security_policy": {Tok: makeResource(mainMod, "nsxt_policy_sharded_security_policy")},
+			"nsxt_policy_security_policy_rule": {Tok: makeResource(mainMod, "nsxt_policy_security_policy_rule")},
+			"nsxt_policy_gateway_policy_rule": {Tok: makeResource(mainMod, "nsxt_policy_gateway_policy_rule")},
+			"nsxt_policy_firewall_exclude_list_member": {Tok: makeResource(mainMod, "nsxt_policy_firewall_exclude_list_member")},
+			"nsxt_policy_bulk_vm_tags": {Tok: makeResource(mainMod, "nsxt_policy_bulk_vm_tags")},
 		},
 		DataSources: map[string]*tfbridge.DataSourceInfo{
 			// Map each resource in the Terraform provider to a Pulumi function. An example
@@ -331,6 +365,21 @@ func Provider() tfbridge.ProviderInfo {
 			"nsxt_transport_node_realization": {Tok: makeDataSource(mainMod, "nsxt_transport_node_realization")},
 			"nsxt_failure_domain": {Tok: makeDataSource(mainMod, "nsxt_failure_domain")},
 			"nsxt_compute_collection": {Tok: makeDataSource(mainMod, "nsxt_compute_collection")},
+			"nsxt_nsx_capabilities": {Tok: makeDataSource(mainMod, "nsxt_nsx_capabilities")},
+			// search is named after what it does, not get* like the lookups of a single object.
+			"nsxt_search": {Tok: tfbridge.MakeDataSource("nsxt", mainMod, "search")},
+			"nsxt_policy_segments": {Tok: makeDataSource(mainMod, "nsxt_policy_segments")},
+			"nsxt_policy_groups": {Tok: makeDataSource(mainMod, "nsxt_policy_groups")},
+			"nsxt_policy_services": {Tok: makeDataSource(mainMod, "nsxt_policy_services")},
+			"nsxt_policy_tier0_gateways": {Tok: makeDataSource(mainMod, "nsxt_policy_tier0_gateways")},
+			"nsxt_policy_tier1_gateways": {Tok: makeDataSource(mainMod, "nsxt_policy_tier1_gateways")},
+			"nsxt_policy_ip_blocks": {Tok: makeDataSource(mainMod, "nsxt_policy_ip_blocks")},
+			"nsxt_policy_ip_pools": {Tok: makeDataSource(mainMod, "nsxt_policy_ip_pools")},
+			"nsxt_policy_lb_services": {Tok: makeDataSource(mainMod, "nsxt_policy_lb_services")},
+			"nsxt_policy_projects": {Tok: makeDataSource(mainMod, "nsxt_policy_projects")},
+			"nsxt_policy_group_effective_members": {Tok: makeDataSource(mainMod, "nsxt_policy_group_effective_members")},
+			"nsxt_policy_group_criteria": {Tok: makeDataSource(mainMod, "nsxt_policy_group_criteria")},
+			"nsxt_evaluate_flow": {Tok: tfbridge.MakeDataSource("nsxt", mainMod, "evaluateFlow")},
 		},
 		JavaScript: &tfbridge.JavaScriptInfo{
 			PackageName: "@SCC-Hyperscale-fr/nsxt",
@@ -378,6 +427,8 @@ func Provider() tfbridge.ProviderInfo {
 	}
 
 	prov.SetAutonaming(255, "-")
+	gateVersions(&prov, conn)
+	analyzeRules(&prov)
 
 	return prov
 }
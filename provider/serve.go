@@ -0,0 +1,67 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxt
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/server"
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/telemetry"
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/version"
+)
+
+// flushTimeout bounds the time spent exporting the pending spans when the provider stops.
+const flushTimeout = 5 * time.Second
+
+// Main serves the provider, with the middlewares that need the Pulumi side of each operation in
+// front of the bridge.
+func Main(pulumiSchema []byte) {
+	conn := nsxapi.NewConnection()
+	prov := newProvider(conn)
+	var middlewares []server.Middleware
+	flush := func(context.Context) {}
+	if telemetry.Enabled() {
+		var err error
+		if flush, err = telemetry.Start(context.Background(), version.Version); err != nil {
+			log.Printf("[WARN] tracing disabled: %v", err)
+			flush = func(context.Context) {}
+		} else {
+			// Outermost, so that the operations tracked below carry their span.
+			middlewares = append(middlewares, server.Trace(flush))
+		}
+	}
+	middlewares = append(middlewares,
+		server.Offline(conn.Unreachable, pulumiSchema),
+		server.ReadOnly(conn.ReadOnly),
+		server.Track(conn.Operations()),
+		server.RuleDiff(ruleListTypes(prov)...),
+		server.DFWSnapshot(func() server.Drafts {
+			if c := conn.Client(); c != nil && conn.DFWSnapshot() {
+				return c
+			}
+			return nil
+		}, dfwTypes(prov)...),
+	)
+	server.Main("nsxt", version.Version, prov, pulumiSchema, conn.Redactor(), middlewares...)
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	flush(ctx)
+	cancel()
+	if err := conn.Close(); err != nil {
+		log.Printf("[WARN] %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2016-2018, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nsxt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pulumi/pulumi-terraform-bridge/v3/pkg/tfbridge"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/SCC-Hyperscale-fr/pulumi-nsxt/provider/pkg/nsxapi"
+)
+
+// minResourceVersions are the NSX versions resources need, as documented upstream. On older
+// managers they fail deep into the apply, often with a bare 404.
+var minResourceVersions = map[string]string{
+	"nsxt_policy_project":                     "4.0.0",
+	"nsxt_policy_host_transport_node_profile": "4.1.0",
+	"nsxt_policy_evpn_config":                 "3.1.0",
+	"nsxt_policy_evpn_tenant":                 "3.1.0",
+	"nsxt_policy_evpn_tunnel_endpoint":        "3.1.0",
+	"nsxt_policy_intrusion_service_policy":    "3.1.0",
+	"nsxt_policy_intrusion_service_profile":   "3.1.0",
+}
+
+// minFieldVersions are the NSX versions fields need, by resource and Pulumi property name. The
+// fields under "*" apply to every resource having them.
+var minFieldVersions = map[string]map[string]string{
+	"*": {
+		"context": "4.0.0", // projects
+	},
+	"nsxt_policy_group": {
+		"extendedCriteria": "3.1.0",
+		"groupType":        "3.2.0",
+	},
+	"nsxt_policy_segment": {
+		"bridgeConfigs": "3.0.0",
+	},
+	"nsxt_policy_fixed_segment": {
+		"bridgeConfigs": "3.0.0",
+	},
+}
+
+// gateVersions makes every resource check, from preview on, that the manager is recent enough for
+// it and for the fields it sets.
+func gateVersions(prov *tfbridge.ProviderInfo, conn *nsxapi.Connection) {
+	for name, info := range prov.Resources {
+		info.PreCheckCallback = checkVersion(conn, name, info.Tok.Name().String())
+	}
+}
+
+// checkVersion returns the version check of the resource with the given Terraform and Pulumi
+// names. The check is skipped during an offline preview, and with a warning when the version of
+// the manager can't be read.
+func checkVersion(conn *nsxapi.Connection, name, displayName string) tfbridge.PreCheckCallback {
+	return func(ctx context.Context, config, _ resource.PropertyMap) (resource.PropertyMap, error) {
+		type requirement struct{ what, min string }
+		var requirements []requirement
+		if min, ok := minResourceVersions[name]; ok {
+			requirements = append(requirements, requirement{displayName, min})
+		}
+		for _, fields := range []map[string]string{minFieldVersions["*"], minFieldVersions[name]} {
+			for field, min := range fields {
+				if isSet(config[resource.PropertyKey(field)]) {
+					requirements = append(requirements, requirement{displayName + "." + field, min})
+				}
+			}
+		}
+		sort.Slice(requirements, func(i, j int) bool { return requirements[i].what < requirements[j].what })
+		if len(requirements) == 0 || conn.Client() == nil {
+			return config, nil
+		}
+
+		version, err := conn.Version(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				tfbridge.GetLogger(ctx).Warn(fmt.Sprintf("cannot check the NSX version required by %s: %v",
+					displayName, err))
+			}
+			return config, nil
+		}
+		for _, req := range requirements {
+			min, err := nsxapi.ParseVersion(req.min)
+			if err != nil {
+				return nil, err
+			}
+			if !version.AtLeast(min) {
+				return nil, fmt.Errorf("%s requires NSX %s or later, but the manager runs %s",
+					req.what, req.min, version)
+			}
+		}
+		return config, nil
+	}
+}
+
+// isSet tells whether a property is given a value, unknown values included.
+func isSet(v resource.PropertyValue) bool {
+	switch {
+	case v.IsNull():
+		return false
+	case v.IsSecret():
+		return isSet(v.SecretValue().Element)
+	case v.IsArray():
+		return len(v.ArrayValue()) > 0
+	case v.IsObject():
+		return len(v.ObjectValue()) > 0
+	}
+	return true
+}